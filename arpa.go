@@ -0,0 +1,319 @@
+package randtxt
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/pboyd/markov"
+)
+
+const (
+	// stationaryIterations limits the number of iterations used to find
+	// how often each tag occurs.
+	stationaryIterations = 10000
+
+	// stationaryTolerance is the change in the tag frequencies that's
+	// small enough to stop iterating.
+	stationaryTolerance = 1e-12
+)
+
+// WriteARPA writes the chain to "w" as an n-gram language model in the ARPA
+// format. Each tag is a token, in the same "Text/POS" form that's stored in
+// the chain. Sentence markers are written as "<s>" and "</s>".
+//
+// A chain with ngram size n becomes an (n+1)-gram model. The chain only
+// stores probabilities, so the lower orders are estimated from it: the
+// frequency of each tag is found from the chain's transitions, and the
+// shorter ngrams are summed from the longest ones. Back-off weights are
+// omitted, so a reader backs off the same way Model does.
+func WriteARPA(w io.Writer, chain markov.Chain) error {
+	return WriteARPAContext(context.Background(), w, chain)
+}
+
+// WriteARPAContext is like WriteARPA but stops and returns the context's
+// error if the context is cancelled first.
+func WriteARPAContext(ctx context.Context, w io.Writer, chain markov.Chain) error {
+	size, err := inspectChain(chain)
+	if err != nil {
+		return err
+	}
+
+	g, err := readTransitions(ctx, chain, size)
+	if err != nil {
+		return err
+	}
+
+	counts := g.expectedCounts(g.stationary())
+
+	// Tags that can't be reached have no probability to write.
+	for _, ngrams := range counts {
+		for ngram, n := range ngrams {
+			if n <= 0 {
+				delete(ngrams, ngram)
+			}
+		}
+	}
+
+	buf := bufio.NewWriter(w)
+
+	fmt.Fprintln(buf, `\data\`)
+	for order := 1; order <= size+1; order++ {
+		fmt.Fprintf(buf, "ngram %d=%d\n", order, len(counts[order]))
+	}
+
+	for order := 1; order <= size+1; order++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(buf, "\n\\%d-grams:\n", order)
+
+		ngrams := counts[order]
+		totals := map[string]float64{}
+		keys := make([]string, 0, len(ngrams))
+		for ngram, n := range ngrams {
+			totals[dropLast(ngram)] += n
+			keys = append(keys, ngram)
+		}
+		sort.Strings(keys)
+
+		for _, ngram := range keys {
+			p := ngrams[ngram] / totals[dropLast(ngram)]
+			fmt.Fprintf(buf, "%.6f\t%s\n", math.Log10(p), ngram)
+		}
+	}
+
+	fmt.Fprintln(buf, "\n\\end\\")
+
+	return buf.Flush()
+}
+
+// transitions holds the links of the chain that ARPA export needs. Tags and
+// ngrams are identified by their raw values.
+type transitions struct {
+	size int
+
+	// next maps each full size ngram to the probability of each tag
+	// following it.
+	next map[string]map[string]float64
+
+	// ngrams maps each tag to the probability of each full size ngram
+	// that ends with it. It's nil for unigram chains, where the tags are
+	// the ngrams.
+	ngrams map[string]map[string]float64
+
+	tags []string
+}
+
+// readTransitions walks the chain and reads its transitions.
+func readTransitions(ctx context.Context, chain markov.Chain, size int) (*transitions, error) {
+	g := &transitions{
+		size: size,
+		next: map[string]map[string]float64{},
+	}
+	if size > 1 {
+		g.ngrams = map[string]map[string]float64{}
+	}
+
+	// Read every value first, since the links only have IDs.
+	values := map[int]string{}
+	err := walkChain(ctx, chain, func(id int, value string) error {
+		values[id] = value
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int, 0, len(values))
+	for id := range values {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		value := values[id]
+		length := contextLength(value)
+
+		if length != 1 && length != size {
+			continue
+		}
+
+		links, err := chain.Links(id)
+		if err != nil {
+			return nil, err
+		}
+
+		if length == 1 {
+			g.tags = append(g.tags, value)
+		}
+
+		if length == size {
+			// An ngram links to the tags that follow it.
+			g.next[value] = linkedValues(links, values, 1)
+		} else {
+			// A tag links to the ngrams that end with it.
+			g.ngrams[value] = linkedValues(links, values, size)
+		}
+	}
+
+	sort.Strings(g.tags)
+	return g, nil
+}
+
+// linkedValues returns the probability of each linked value that has
+// "length" tags, rescaled so they add up to 1.
+func linkedValues(links []markov.Link, values map[int]string, length int) map[string]float64 {
+	linked := map[string]float64{}
+	total := 0.0
+
+	for _, link := range links {
+		value, ok := values[link.ID]
+		if !ok || contextLength(value) != length {
+			continue
+		}
+
+		linked[value] += link.Probability
+		total += link.Probability
+	}
+
+	for value := range linked {
+		linked[value] /= total
+	}
+
+	return linked
+}
+
+// step returns the probability of each tag following "tag".
+func (g *transitions) step(tag string) map[string]float64 {
+	if g.ngrams == nil {
+		return g.next[tag]
+	}
+
+	next := map[string]float64{}
+	for ngram, p := range g.ngrams[tag] {
+		for following, q := range g.next[ngram] {
+			next[following] += p * q
+		}
+	}
+	return next
+}
+
+// stationary finds how often each tag occurs, as the stationary distribution
+// of the tag transitions. Probability that reaches a dead end is spread
+// evenly over every tag.
+func (g *transitions) stationary() map[string]float64 {
+	index := make(map[string]int, len(g.tags))
+	for i, tag := range g.tags {
+		index[tag] = i
+	}
+
+	type edge struct {
+		to int
+		p  float64
+	}
+
+	edges := make([][]edge, len(g.tags))
+	for i, tag := range g.tags {
+		for following, p := range g.step(tag) {
+			j, ok := index[following]
+			if ok {
+				edges[i] = append(edges[i], edge{j, p})
+			}
+		}
+	}
+
+	n := float64(len(g.tags))
+	freq := make([]float64, len(g.tags))
+	for i := range freq {
+		freq[i] = 1 / n
+	}
+	next := make([]float64, len(g.tags))
+
+	for iteration := 0; iteration < stationaryIterations; iteration++ {
+		lost := 0.0
+		for i := range next {
+			next[i] = 0
+		}
+
+		for i, out := range edges {
+			if len(out) == 0 {
+				lost += freq[i]
+				continue
+			}
+
+			for _, e := range out {
+				next[e.to] += freq[i] * e.p
+			}
+		}
+
+		// Average with the previous step so periodic chains
+		// converge.
+		change := 0.0
+		for i := range freq {
+			updated := (freq[i] + next[i] + lost/n) / 2
+			change += math.Abs(updated - freq[i])
+			freq[i] = updated
+		}
+
+		if change < stationaryTolerance {
+			break
+		}
+	}
+
+	result := make(map[string]float64, len(g.tags))
+	for i, tag := range g.tags {
+		result[tag] = freq[i]
+	}
+	return result
+}
+
+// expectedCounts returns the relative frequency of every ngram, of every
+// order from 1 to size+1, keyed by order.
+func (g *transitions) expectedCounts(freq map[string]float64) []map[string]float64 {
+	counts := make([]map[string]float64, g.size+2)
+	for i := range counts {
+		counts[i] = map[string]float64{}
+	}
+
+	for _, tag := range g.tags {
+		counts[1][tag] += freq[tag]
+	}
+
+	for ngram, next := range g.next {
+		context := strings.Split(ngram, " ")
+
+		weight := freq[ngram]
+		if g.ngrams != nil {
+			last := context[len(context)-1]
+			weight = freq[last] * g.ngrams[last][ngram]
+		}
+
+		for tag, p := range next {
+			for start := 0; start < len(context); start++ {
+				key := strings.Join(context[start:], " ") + " " + tag
+				counts[len(context)-start+1][key] += weight * p
+			}
+		}
+	}
+
+	return counts
+}
+
+// dropLast removes the last tag from an ngram.
+func dropLast(ngram string) string {
+	i := strings.LastIndexByte(ngram, ' ')
+	if i < 0 {
+		return ""
+	}
+	return ngram[:i]
+}
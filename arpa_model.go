@@ -0,0 +1,347 @@
+package randtxt
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pboyd/markov"
+)
+
+// arpaZero is the log probability at or below which ARPA files mean zero.
+const arpaZero = -99
+
+// ARPAModel is an n-gram language model read from an ARPA file.
+//
+// It's an Estimator, so it can be used with Model and Scorer directly, and
+// it can be written to a chain for Generator with WriteChain.
+type ARPAModel struct {
+	order int
+
+	// ngrams maps the raw tags of each ngram, joined by spaces, to its
+	// log10 probability and back-off weight.
+	ngrams map[string]arpaEntry
+
+	// byOrder lists the ngrams of each order, sorted.
+	byOrder [][]string
+}
+
+type arpaEntry struct {
+	logProb float64
+	backoff float64
+}
+
+// ReadARPA reads an ARPA n-gram language model.
+//
+// Tokens in "Text/POS" form become tags, as do "<s>" and "</s>". The POS is
+// split from the last "/", and escapes are decoded as in Tag.String. Tokens
+// without a POS are given "fallbackPOS". If "fallbackPOS" is blank they're
+// an error.
+func ReadARPA(r io.Reader, fallbackPOS string) (*ARPAModel, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<24)
+	line := 0
+
+	errorf := func(format string, args ...interface{}) error {
+		return fmt.Errorf("ARPA line %d: %s", line, fmt.Sprintf(format, args...))
+	}
+
+	next := func() (string, bool) {
+		for scanner.Scan() {
+			line++
+			text := strings.TrimSpace(scanner.Text())
+			if text != "" {
+				return text, true
+			}
+		}
+		return "", false
+	}
+
+	// Anything before \data\ is a comment.
+	text, ok := next()
+	for ok && text != `\data\` {
+		text, ok = next()
+	}
+	if !ok {
+		return nil, readARPAError(scanner, `missing \data\`)
+	}
+
+	var declared []int
+	for {
+		text, ok = next()
+		if !ok {
+			return nil, readARPAError(scanner, "missing ngrams")
+		}
+
+		if !strings.HasPrefix(text, "ngram ") {
+			break
+		}
+
+		var order, n int
+		_, err := fmt.Sscanf(text, "ngram %d=%d", &order, &n)
+		if err != nil || order != len(declared)+1 {
+			return nil, errorf("invalid ngram count %q", text)
+		}
+		declared = append(declared, n)
+	}
+
+	if len(declared) == 0 {
+		return nil, errorf("no ngram counts")
+	}
+
+	m := &ARPAModel{
+		order:   len(declared),
+		ngrams:  map[string]arpaEntry{},
+		byOrder: make([][]string, len(declared)+1),
+	}
+
+	for order := 1; order <= m.order; order++ {
+		if text != fmt.Sprintf(`\%d-grams:`, order) {
+			return nil, errorf(`got %q, want "\%d-grams:"`, text, order)
+		}
+
+		for {
+			text, ok = next()
+			if !ok {
+				return nil, readARPAError(scanner, `missing \end\`)
+			}
+
+			if strings.HasPrefix(text, `\`) {
+				break
+			}
+
+			fields := strings.Fields(text)
+			if len(fields) != order+1 && len(fields) != order+2 {
+				return nil, errorf("want %d or %d fields, got %d", order+1, order+2, len(fields))
+			}
+
+			entry := arpaEntry{}
+
+			var err error
+			entry.logProb, err = strconv.ParseFloat(fields[0], 64)
+			if err != nil {
+				return nil, errorf("%v", err)
+			}
+
+			if len(fields) == order+2 {
+				entry.backoff, err = strconv.ParseFloat(fields[order+1], 64)
+				if err != nil {
+					return nil, errorf("%v", err)
+				}
+			}
+
+			raw := make([]string, order)
+			for i, token := range fields[1 : order+1] {
+				tag, err := arpaTag(token, fallbackPOS)
+				if err != nil {
+					return nil, errorf("%v", err)
+				}
+				raw[i] = tag.String()
+			}
+
+			key := strings.Join(raw, " ")
+			m.ngrams[key] = entry
+			m.byOrder[order] = append(m.byOrder[order], key)
+		}
+
+		if len(m.byOrder[order]) != declared[order-1] {
+			return nil, errorf("declared %d %d-grams, got %d", declared[order-1], order, len(m.byOrder[order]))
+		}
+		sort.Strings(m.byOrder[order])
+	}
+
+	if text != `\end\` {
+		return nil, errorf(`got %q, want "\end\"`, text)
+	}
+
+	return m, nil
+}
+
+func readARPAError(scanner *bufio.Scanner, msg string) error {
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return errors.New("invalid ARPA file: " + msg)
+}
+
+// arpaTag converts an ARPA token to a tag.
+func arpaTag(token, fallbackPOS string) (Tag, error) {
+	tag := parseTag(token)
+	if tag.Text != "" && tag.POS != "" {
+		return tag, nil
+	}
+
+	if fallbackPOS == "" {
+		return Tag{}, fmt.Errorf("token %q has no POS", token)
+	}
+
+	return Tag{Text: token, POS: fallbackPOS}, nil
+}
+
+// Order returns the length of the longest ngrams in the model.
+func (m *ARPAModel) Order() int {
+	return m.order
+}
+
+// Probability returns the probability of "next" following "context", backing
+// off to shorter contexts as the ARPA format describes.
+func (m *ARPAModel) Probability(context []Tag, next Tag) float64 {
+	context = lastTags(context, m.order-1)
+
+	raw := make([]string, len(context))
+	for i, tag := range context {
+		raw[i] = tag.String()
+	}
+
+	return math.Pow(10, m.logProbability(raw, next.String()))
+}
+
+// logProbability returns the log10 probability of "next" following "past".
+func (m *ARPAModel) logProbability(past []string, next string) float64 {
+	backoff := 0.0
+
+	for start := 0; start <= len(past); start++ {
+		key := strings.Join(append(past[start:len(past):len(past)], next), " ")
+		if entry, ok := m.ngrams[key]; ok {
+			if entry.logProb <= arpaZero {
+				return math.Inf(-1)
+			}
+			return backoff + entry.logProb
+		}
+
+		if start < len(past) {
+			backoff += m.ngrams[strings.Join(past[start:], " ")].backoff
+		}
+	}
+
+	return math.Inf(-1)
+}
+
+// Vocabulary returns every tag in the model's unigrams.
+func (m *ARPAModel) Vocabulary() []Tag {
+	return parseTags(m.byOrder[1])
+}
+
+// HasBackoffWeights tests if any ngram has a back-off weight other than zero,
+// which WriteChain would drop.
+func (m *ARPAModel) HasBackoffWeights() bool {
+	for _, entry := range m.ngrams {
+		if entry.backoff != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteChain writes the model to "chain" in the form ModelBuilder writes a
+// chain with Backoff set, so Generator can use it. The ngram size of the
+// chain is one less than the model's order, which must be at least 2.
+//
+// The chain stores each listed ngram's probability. The back-off weights
+// can't be stored in the chain, so they're lost: a Model backs off to a
+// shorter context only when the longer one has no continuations, and then
+// uses the shorter context's probabilities unweighted. Use HasBackoffWeights
+// to check whether anything is lost, and the ARPAModel as an Estimator to get
+// probabilities that include the weights.
+func (m *ARPAModel) WriteChain(chain markov.WriteChain) error {
+	if m.order < 2 {
+		return fmt.Errorf("a chain needs an ARPA model of order 2 or more, got %d", m.order)
+	}
+
+	size := m.order - 1
+
+	ids := map[string]int{}
+	add := func(value string) (int, error) {
+		if id, ok := ids[value]; ok {
+			return id, nil
+		}
+
+		id, err := chain.Add(value)
+		if err != nil {
+			return 0, err
+		}
+		ids[value] = id
+		return id, nil
+	}
+
+	relate := func(parent, child string, p float64) error {
+		count := probabilityCount(p)
+
+		parentID, err := add(parent)
+		if err != nil {
+			return err
+		}
+
+		childID, err := add(child)
+		if err != nil {
+			return err
+		}
+
+		return chain.Relate(parentID, childID, count)
+	}
+
+	// The longest ngrams are written first, so the root of the chain is
+	// full size.
+	for order := m.order; order >= 2; order-- {
+		for _, ngram := range m.byOrder[order] {
+			entry := m.ngrams[ngram]
+			if entry.logProb <= arpaZero {
+				continue
+			}
+
+			err := relate(dropLast(ngram), lastTag(ngram), math.Pow(10, entry.logProb))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if size == 1 {
+		return nil
+	}
+
+	// Each tag links to the full size ngrams that end with it, weighted by
+	// how often the ngram occurs.
+	linked := map[string]bool{}
+	for _, ngram := range m.byOrder[m.order] {
+		context := dropLast(ngram)
+		// Each context is the prefix of many ngrams, but only needs
+		// to be linked once.
+		if linked[context] {
+			continue
+		}
+		linked[context] = true
+
+		p := m.jointProbability(strings.Split(context, " "))
+		if p <= 0 {
+			continue
+		}
+
+		err := relate(lastTag(context), context, p)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// jointProbability returns the probability of the tags occurring together,
+// by the chain rule.
+func (m *ARPAModel) jointProbability(tags []string) float64 {
+	logProb := 0.0
+	for i := range tags {
+		logProb += m.logProbability(tags[:i], tags[i])
+	}
+	return math.Pow(10, logProb)
+}
+
+// lastTag returns the last tag of an ngram.
+func lastTag(ngram string) string {
+	return ngram[strings.LastIndexByte(ngram, ' ')+1:]
+}
@@ -0,0 +1,281 @@
+package randtxt
+
+import (
+	"bufio"
+	"bytes"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pboyd/markov"
+)
+
+func TestWriteARPA(t *testing.T) {
+	chain, close := testChain(t, "testfiles/ion/trigram.mkv")
+	defer close()
+
+	buf := &bytes.Buffer{}
+	err := WriteARPA(buf, chain)
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	declared, ngrams := parseTestARPA(t, buf.String())
+
+	if len(declared) != 4 {
+		t.Fatalf("got %d orders, want 4", len(declared))
+	}
+
+	for order, n := range declared {
+		if len(ngrams[order]) != n {
+			t.Errorf("order %d: declared %d ngrams, got %d", order, n, len(ngrams[order]))
+		}
+
+		// The probabilities following each context add up to 1.
+		totals := map[string]float64{}
+		for ngram, logp := range ngrams[order] {
+			totals[dropLast(ngram)] += math.Pow(10, logp)
+		}
+
+		for context, total := range totals {
+			if math.Abs(total-1) > 0.001 {
+				t.Errorf("order %d: probabilities after %q add up to %f", order, context, total)
+			}
+		}
+	}
+
+	// "?" is 88 of the 4261 tags in the source text.
+	p := math.Pow(10, ngrams[1]["?/."])
+	if math.Abs(p-88.0/4261) > 0.001 {
+		t.Errorf("got unigram probability %f for \"?/.\", want %f", p, 88.0/4261)
+	}
+
+	// The longest ngrams have the chain's probabilities.
+	id, err := chain.Find("welcome/UH ,/, ion/NN")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	links, err := chain.Links(id)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, link := range links {
+		next, _ := chain.Get(link.ID)
+		ngram := "welcome/UH ,/, ion/NN " + next.(string)
+
+		got := math.Pow(10, ngrams[4][ngram])
+		if math.Abs(got-link.Probability) > 0.0001 {
+			t.Errorf("%q: got %f, want %f", ngram, got, link.Probability)
+		}
+	}
+}
+
+func TestARPARoundTrip(t *testing.T) {
+	chain, close := testChain(t, "testfiles/ion/trigram.mkv")
+	defer close()
+
+	buf := &bytes.Buffer{}
+	err := WriteARPA(buf, chain)
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	model, err := ReadARPA(buf, "")
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	if model.Order() != 4 {
+		t.Errorf("got order %d, want 4", model.Order())
+	}
+
+	imported := markov.NewMemoryChain(0)
+	err = model.WriteChain(imported)
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	g, err := NewGenerator(imported, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("invalid chain: %v", err)
+	}
+
+	text, err := g.Paragraph(2, 4)
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+	checkParagraph(t, text, 2, 4)
+
+	// The imported chain should score the source text about as well as
+	// the original.
+	tags := readTestFile(t, "testfiles/ion/tagged.tsv", ReadTSV)
+	perplexity := func(chain markov.Chain) float64 {
+		s, err := NewScorer(chain)
+		if err != nil {
+			t.Fatalf("invalid chain: %v", err)
+		}
+
+		score, err := s.Score(tags)
+		if err != nil {
+			t.Fatalf("got error %v, want nil", err)
+		}
+
+		return score.Perplexity()
+	}
+
+	want := perplexity(chain)
+	got := perplexity(imported)
+	if math.Abs(got-want)/want > 0.01 {
+		t.Errorf("got perplexity %f, want %f", got, want)
+	}
+}
+
+const testARPA = `an example model
+
+\data\
+ngram 1=4
+ngram 2=2
+
+\1-grams:
+-0.5	the	-0.3
+-0.6	poet	-0.2
+-1.0	sings/VBZ
+-99	<s>	-0.1
+
+\2-grams:
+-0.1	<s> the
+-0.2	the poet
+
+\end\
+`
+
+func TestReadARPA(t *testing.T) {
+	model, err := ReadARPA(strings.NewReader(testARPA), "NN")
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	the := Tag{Text: "the", POS: "NN"}
+	poet := Tag{Text: "poet", POS: "NN"}
+	sings := Tag{Text: "sings", POS: "VBZ"}
+
+	cases := []struct {
+		context []Tag
+		next    Tag
+		want    float64
+	}{
+		{[]Tag{the}, poet, -0.2},
+		{[]Tag{SentenceStartTag}, the, -0.1},
+		// Backs off with the weight of "the".
+		{[]Tag{the}, sings, -0.3 + -1.0},
+		// "sings" has no back-off weight.
+		{[]Tag{sings}, poet, -0.6},
+		{nil, the, -0.5},
+		{[]Tag{the}, SentenceStartTag, math.Inf(-1)},
+	}
+
+	for _, c := range cases {
+		got := math.Log10(model.Probability(c.context, c.next))
+		if math.Abs(got-c.want) > 1e-9 && !(math.IsInf(got, -1) && math.IsInf(c.want, -1)) {
+			t.Errorf("%v after %v: got %f, want %f", c.next, c.context, got, c.want)
+		}
+	}
+
+	if !model.HasBackoffWeights() {
+		t.Errorf("got no back-off weights, want some")
+	}
+
+	chain := markov.NewMemoryChain(0)
+	err = model.WriteChain(chain)
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	size, err := inspectChain(chain)
+	if err != nil {
+		t.Fatalf("invalid chain: %v", err)
+	}
+	if size != 1 {
+		t.Errorf("got ngram size %d, want 1", size)
+	}
+}
+
+func TestHasBackoffWeights(t *testing.T) {
+	const arpa = `\data\
+ngram 1=2
+
+\1-grams:
+-0.3	the	0
+-0.2	poet
+
+\end\
+`
+	model, err := ReadARPA(strings.NewReader(arpa), "NN")
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	if model.HasBackoffWeights() {
+		t.Errorf("got back-off weights, want none")
+	}
+}
+
+func TestReadARPAErrors(t *testing.T) {
+	cases := []struct {
+		name        string
+		arpa        string
+		fallbackPOS string
+	}{
+		{"untagged token", testARPA, ""},
+		{"missing data", "ngram 1=1\n", "NN"},
+		{"wrong count", strings.Replace(testARPA, "ngram 2=2", "ngram 2=3", 1), "NN"},
+		{"missing end", strings.Replace(testARPA, "\\end\\", "", 1), "NN"},
+		{"invalid probability", strings.Replace(testARPA, "-0.1\t<s> the", "x\t<s> the", 1), "NN"},
+	}
+
+	for _, c := range cases {
+		_, err := ReadARPA(strings.NewReader(c.arpa), c.fallbackPOS)
+		if err == nil {
+			t.Errorf("%s: got nil error", c.name)
+		}
+	}
+}
+
+// parseTestARPA returns the number of ngrams declared for each order and the
+// log probability of each ngram.
+func parseTestARPA(t *testing.T, text string) (map[int]int, map[int]map[string]float64) {
+	t.Helper()
+
+	declared := map[int]int{}
+	ngrams := map[int]map[string]float64{}
+	order := 0
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case line == "" || line == `\data\` || line == `\end\`:
+		case strings.HasPrefix(line, "ngram "):
+			split := strings.Split(line[len("ngram "):], "=")
+			o, _ := strconv.Atoi(split[0])
+			declared[o], _ = strconv.Atoi(split[1])
+		case strings.HasSuffix(line, "-grams:"):
+			order, _ = strconv.Atoi(line[1:strings.IndexByte(line, '-')])
+			ngrams[order] = map[string]float64{}
+		default:
+			fields := strings.Split(line, "\t")
+			logp, err := strconv.ParseFloat(fields[0], 64)
+			if err != nil {
+				t.Fatalf("invalid line %q: %v", line, err)
+			}
+			ngrams[order][fields[1]] = logp
+		}
+	}
+
+	return declared, ngrams
+}
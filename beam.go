@@ -0,0 +1,121 @@
+package randtxt
+
+import (
+	"context"
+	"math"
+	"sort"
+)
+
+// maxBeamTags is the longest sentence, in tags, that BeamSearch will consider.
+const maxBeamTags = 200
+
+// ScoredSentence is a sentence found by Model.BeamSearch.
+type ScoredSentence struct {
+	// Tags are the tags in the sentence after the model's starting
+	// context. Boundary tags are not included.
+	Tags []Tag
+
+	// LogProbability is the natural log of the probability of the
+	// sentence following the starting context.
+	LogProbability float64
+}
+
+// BeamSearch returns the "k" most probable sentences that follow the model's
+// current context, most probable first. A sentence ends with a tag that ends
+// a sentence in the model's TagSet, or with SentenceEndTag.
+//
+// Only the "width" most probable partial sentences are kept at each step, so
+// the result is approximate. Wider beams are slower but more accurate. The
+// model's Sampling and Steering are ignored and the model isn't advanced.
+func (m *Model) BeamSearch(width, k int) ([]ScoredSentence, error) {
+	return m.BeamSearchContext(context.Background(), width, k)
+}
+
+// BeamSearchContext is like BeamSearch but stops and returns the context's
+// error if the context is cancelled first.
+func (m *Model) BeamSearchContext(ctx context.Context, width, k int) ([]ScoredSentence, error) {
+	if width < 1 || k < 1 {
+		return nil, nil
+	}
+
+	beam := []beamState{{past: m.past}}
+	var finished []ScoredSentence
+
+	for step := 0; step < maxBeamTags && len(beam) > 0; step++ {
+		var next []beamState
+
+		for _, state := range beam {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+
+			links, err := m.backoffLinks(state.past)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, link := range links {
+				if link.Probability <= 0 {
+					continue
+				}
+
+				raw, err := m.chain.Get(link.ID)
+				if err != nil {
+					return nil, err
+				}
+
+				tag := parseTag(raw.(string))
+				extended := beamState{
+					past:    shiftPast(state.past, raw.(string)),
+					tags:    state.tags,
+					logProb: state.logProb + math.Log(link.Probability),
+				}
+
+				if !tag.IsBoundary() {
+					extended.tags = append(state.tags[:len(state.tags):len(state.tags)], tag)
+				}
+
+				if tag == SentenceEndTag || EndsSentence(m.TagSet, tag) {
+					finished = append(finished, ScoredSentence{
+						Tags:           extended.tags,
+						LogProbability: extended.logProb,
+					})
+					continue
+				}
+
+				next = append(next, extended)
+			}
+		}
+
+		sort.SliceStable(next, func(i, j int) bool {
+			return next[i].logProb > next[j].logProb
+		})
+		if len(next) > width {
+			next = next[:width]
+		}
+		beam = next
+
+		sort.SliceStable(finished, func(i, j int) bool {
+			return finished[i].LogProbability > finished[j].LogProbability
+		})
+		if len(finished) > k {
+			finished = finished[:k]
+		}
+
+		// Probabilities only go down as sentences get longer, so once
+		// the k-th sentence beats everything left in the beam nothing
+		// can replace it.
+		if len(finished) == k && (len(beam) == 0 || finished[k-1].LogProbability >= beam[0].logProb) {
+			break
+		}
+	}
+
+	return finished, nil
+}
+
+// beamState is a partial sentence in BeamSearch.
+type beamState struct {
+	past    []string
+	tags    []Tag
+	logProb float64
+}
@@ -0,0 +1,145 @@
+package randtxt
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/pboyd/markov"
+)
+
+func TestBeamSearch(t *testing.T) {
+	chain, close := testChain(t, "testfiles/ion/trigram.mkv")
+	defer close()
+
+	model, err := NewModel(chain, "", rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	const k = 5
+
+	sentences, err := model.BeamSearch(10, k)
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	if len(sentences) != k {
+		t.Fatalf("got %d sentences, want %d", len(sentences), k)
+	}
+
+	for i, s := range sentences {
+		if len(s.Tags) == 0 || s.Tags[len(s.Tags)-1].POS != "." {
+			t.Errorf("sentence %d doesn't end with a \".\" tag: %v", i, s.Tags)
+		}
+
+		if s.LogProbability > 0 {
+			t.Errorf("sentence %d: got log probability %f, want <= 0", i, s.LogProbability)
+		}
+
+		if i > 0 && s.LogProbability > sentences[i-1].LogProbability {
+			t.Errorf("sentence %d is more probable than sentence %d", i, i-1)
+		}
+
+		want := pathLogProbability(t, model, s.Tags)
+		if math.Abs(want-s.LogProbability) > 1e-9 {
+			t.Errorf("sentence %d: got log probability %f, want %f", i, s.LogProbability, want)
+		}
+	}
+}
+
+func TestBeamSearchWidth(t *testing.T) {
+	chain, close := testChain(t, "testfiles/ion/trigram.mkv")
+	defer close()
+
+	model, err := NewModel(chain, "", rand.New(rand.NewSource(2)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	narrow, err := model.BeamSearch(1, 1)
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	wide, err := model.BeamSearch(50, 1)
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	if len(narrow) != 1 || len(wide) != 1 {
+		t.Fatalf("got %d and %d sentences, want 1", len(narrow), len(wide))
+	}
+
+	if wide[0].LogProbability < narrow[0].LogProbability {
+		t.Errorf("wide beam found %f, narrow beam found %f", wide[0].LogProbability, narrow[0].LogProbability)
+	}
+}
+
+func TestBeamSearchUniversal(t *testing.T) {
+	chain := markov.NewMemoryChain(0)
+	b := NewModelBuilder(chain, 2)
+	b.TagSet = UniversalTagSet
+	err := b.Feed(sliceFeed(readTestFile(t, "testfiles/ion/universal.tsv", ReadTSV)...))
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	model, err := NewModel(chain, "", rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if model.TagSet != UniversalTagSet {
+		t.Fatalf("got TagSet %s, want universal", TagSetName(model.TagSet))
+	}
+
+	sentences, err := model.BeamSearch(10, 3)
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	if len(sentences) != 3 {
+		t.Fatalf("got %d sentences, want 3", len(sentences))
+	}
+
+	for i, s := range sentences {
+		if len(s.Tags) == 0 || !UniversalTagSet.EndsSentence(s.Tags[len(s.Tags)-1]) {
+			t.Errorf("sentence %d doesn't end a sentence: %v", i, s.Tags)
+		}
+	}
+}
+
+// pathLogProbability returns the log probability of the model following
+// "tags" from its current context.
+func pathLogProbability(t *testing.T, m *Model, tags []Tag) float64 {
+	t.Helper()
+
+	past := m.past
+	total := 0.0
+
+	for _, tag := range tags {
+		links, err := m.backoffLinks(past)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		found := false
+		for _, link := range links {
+			raw, _ := m.chain.Get(link.ID)
+			if raw.(string) == tag.String() {
+				total += math.Log(link.Probability)
+				past = shiftPast(past, raw.(string))
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			t.Fatalf("%q can't follow %q", tag, strings.Join(past, " "))
+		}
+	}
+
+	return total
+}
@@ -1,7 +1,11 @@
 package randtxt
 
 import (
+	"context"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/pboyd/markov"
 )
@@ -11,6 +15,34 @@ type ModelBuilder struct {
 	chain     markov.WriteChain
 	ngramSize int
 	TagSet    TagSet
+
+	// encodingChecked is set once the chain's tag encoding has passed
+	// CheckTagEncoding. Everything the builder writes after that is
+	// escaped, so it doesn't have to be checked again.
+	encodingChecked bool
+
+	// Backoff writes every ngram size from the configured size down to 1.
+	// Model uses the shorter ngrams to back off to a shorter context when
+	// the full context has no continuations.
+	Backoff bool
+
+	// SentenceMarkers surrounds every sentence with SentenceStartTag and
+	// SentenceEndTag, so Generator can start at the beginning of a
+	// sentence and stop at the end of one. ParagraphBreakTag is also
+	// kept if the sources send it.
+	//
+	// Sources can send SentenceEndTag to end a sentence that the TagSet
+	// wouldn't, such as one without final punctuation. It's ignored if
+	// the sentence has already ended.
+	SentenceMarkers bool
+
+	// Sources are the names of the corpus files, which are recorded in
+	// the chain's metadata. It's optional.
+	Sources []string
+
+	// Counts, if set, records the ngram counts of the model for the
+	// smoothed estimators. See NewKneserNey and NewAddK.
+	Counts *Counts
 }
 
 // NewModelBuilder creates a ModelBuilder instance.
@@ -31,54 +63,385 @@ func NewModelBuilder(chain markov.WriteChain, ngramSize int) *ModelBuilder {
 
 // Feed reads tags from one or more channels and writes them to the output
 // chain.
+//
+// Blocks until all the channels have been closed. If the chain returns an
+// error Feed returns it, leaving unread values on the channels.
+//
+// Returns ErrLegacyTags, before reading anything, if the chain has to be
+// converted with MigrateChain first. The chain is checked by the first Feed
+// that passes, not every one.
 func (b *ModelBuilder) Feed(sources ...<-chan Tag) error {
-	ngrams := make([]<-chan interface{}, len(sources))
-	for i, source := range sources {
-		ngrams[i] = b.joinTags(source)
+	return b.FeedContext(context.Background(), sources...)
+}
+
+// FeedContext is like Feed but stops reading and returns the context's error
+// if the context is cancelled before the channels are closed.
+func (b *ModelBuilder) FeedContext(ctx context.Context, sources ...<-chan Tag) error {
+	if readable, ok := b.chain.(markov.Chain); ok && !b.encodingChecked {
+		err := CheckTagEncoding(readable)
+		if err != nil {
+			return err
+		}
+		b.encodingChecked = true
+	}
+
+	chain := &lockedChain{chain: b.chain}
+	var tokens, wrote int64
+
+	var wg sync.WaitGroup
+	wg.Add(len(sources))
+
+	errs := make(chan error, len(sources))
+
+	for _, source := range sources {
+		go func(tags <-chan Tag) {
+			defer wg.Done()
+			n, full, err := b.feedOne(ctx, chain, tags)
+			atomic.AddInt64(&tokens, int64(n))
+			if full {
+				atomic.StoreInt64(&wrote, 1)
+			}
+			errs <- err
+		}(source)
+	}
+
+	wg.Wait()
+
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	// Sources shorter than the ngram size aren't written, and metadata
+	// alone would become the root of an empty chain.
+	if tokens == 0 || wrote == 0 {
+		return nil
+	}
+
+	return writeMetadata(b.chain, &Metadata{
+		NgramSize:       b.ngramSize,
+		TagSet:          TagSetName(b.TagSet),
+		Built:           time.Now(),
+		Files:           b.Sources,
+		Tokens:          int(tokens),
+		Backoff:         b.Backoff,
+		SentenceMarkers: b.SentenceMarkers,
+		TagEncoding:     tagEncoding,
+	})
+}
+
+// FeedReaders reads tags from one or more TagReaders and writes them to the
+// output chain, like Feed.
+//
+// If a reader returns an error other than io.EOF, the build stops and
+// FeedReaders returns the error. The chain is left with the tags that were
+// already written, and no metadata is recorded.
+func (b *ModelBuilder) FeedReaders(readers ...TagReader) error {
+	return b.FeedReadersContext(context.Background(), readers...)
+}
+
+// FeedReadersContext is like FeedReaders but stops reading and returns the
+// context's error if the context is cancelled first.
+func (b *ModelBuilder) FeedReadersContext(ctx context.Context, readers ...TagReader) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var once sync.Once
+	var readErr error
+	fail := func(err error) {
+		once.Do(func() {
+			readErr = err
+			cancel()
+		})
 	}
 
-	return markov.Feed(b.chain, ngrams...)
+	sources := make([]<-chan Tag, len(readers))
+	for i, r := range readers {
+		sources[i] = readTags(ctx, r, fail)
+	}
+
+	err := b.FeedContext(ctx, sources...)
+
+	// Stop the readers, if they're still going, so readErr can be
+	// checked safely.
+	fail(nil)
+
+	if readErr != nil {
+		return readErr
+	}
+
+	return err
 }
 
-func (b *ModelBuilder) joinTags(tags <-chan Tag) <-chan interface{} {
-	ngrams := make(chan interface{})
+// feedOne writes the tags from one source to the chain. It returns the number
+// of tokens read, and whether any full size ngram was written.
+func (b *ModelBuilder) feedOne(ctx context.Context, chain markov.WriteChain, tags <-chan Tag) (int, bool, error) {
+	tokens := 0
+	write, wrote := b.ngramWriters(chain)
+	if b.Counts != nil {
+		write = b.counter(write)
+	}
 
-	go func() {
-		defer close(ngrams)
+	prep := &tagPreparer{
+		tagSet:  b.TagSet,
+		markers: b.SentenceMarkers,
+	}
 
-		var prev Tag
-		ngram := make([]string, 0, b.ngramSize)
+	for {
+		var tag Tag
+		var ok bool
 
-		for tag := range tags {
-			tag = b.TagSet.Normalize(tag, prev)
-			if tag.Text == "" {
-				continue
+		select {
+		case tag, ok = <-tags:
+		case <-ctx.Done():
+			return tokens, wrote(), ctx.Err()
+		}
+
+		if !ok {
+			break
+		}
+
+		for _, prepared := range prep.add(tag) {
+			err := write(prepared)
+			if err != nil {
+				return tokens, wrote(), err
 			}
-			prev = tag
 
-			gram := tag.String()
+			if !prepared.IsBoundary() {
+				tokens++
+			}
+		}
+	}
+
+	for _, prepared := range prep.end() {
+		err := write(prepared)
+		if err != nil {
+			return tokens, wrote(), err
+		}
+	}
+
+	return tokens, wrote(), nil
+}
+
+// counter returns a function that adds each tag to the builder's Counts
+// before passing it to "write".
+func (b *ModelBuilder) counter(write func(Tag) error) func(Tag) error {
+	past := make([]string, 0, b.ngramSize)
+
+	return func(tag Tag) error {
+		raw := tag.String()
+		b.Counts.add(past, raw)
+
+		if len(past) < b.ngramSize {
+			past = append(past, raw)
+		} else {
+			past = shiftPast(past, raw)
+		}
+
+		return write(tag)
+	}
+}
+
+// tagPreparer converts source tags to the tags that are stored in the chain.
+// It normalizes them and adds boundary tags.
+type tagPreparer struct {
+	tagSet  TagSet
+	markers bool
+
+	prev       Tag
+	inSentence bool
+}
 
-			if b.ngramSize == 1 {
-				ngrams <- gram
-				continue
+// add returns the tags to store for the next source tag, if any.
+func (p *tagPreparer) add(tag Tag) []Tag {
+	if tag == ParagraphBreakTag {
+		if !p.markers {
+			return nil
+		}
+
+		var prepared []Tag
+		if p.inSentence {
+			prepared = append(prepared, SentenceEndTag)
+			p.inSentence = false
+		}
+
+		p.prev = Tag{}
+		return append(prepared, ParagraphBreakTag)
+	}
+
+	if tag == SentenceEndTag {
+		p.prev = Tag{}
+		if !p.inSentence {
+			return nil
+		}
+
+		p.inSentence = false
+		return []Tag{SentenceEndTag}
+	}
+
+	tag = p.tagSet.Normalize(tag, p.prev)
+	if tag.Text == "" {
+		return nil
+	}
+	p.prev = tag
+
+	var prepared []Tag
+	if p.markers && !p.inSentence {
+		prepared = append(prepared, SentenceStartTag)
+		p.inSentence = true
+	}
+
+	prepared = append(prepared, tag)
+
+	if p.markers && EndsSentence(p.tagSet, tag) {
+		prepared = append(prepared, SentenceEndTag)
+		p.inSentence = false
+	}
+
+	return prepared
+}
+
+// end returns the tags to store after the last source tag.
+func (p *tagPreparer) end() []Tag {
+	if p.inSentence {
+		p.inSentence = false
+		return []Tag{SentenceEndTag}
+	}
+
+	return nil
+}
+
+// ngramWriters returns a function that writes each tag to an ngramWriter for
+// every ngram size the builder writes, and a function that reports whether a
+// full size ngram has been written yet.
+func (b *ModelBuilder) ngramWriters(chain markov.WriteChain) (func(Tag) error, func() bool) {
+	writers := make([]*ngramWriter, 0, b.ngramSize)
+	for size := b.ngramSize; size > 0; size-- {
+		writers = append(writers, &ngramWriter{
+			chain: chain,
+			size:  size,
+		})
+
+		if !b.Backoff {
+			break
+		}
+	}
+
+	// Lower order ngrams are held back until the first full size ngram
+	// has been written, so the root of a new chain is always full size.
+	var pending []string
+
+	wrote := func() bool {
+		return writers[0].started
+	}
+
+	return func(tag Tag) error {
+		gram := tag.String()
+
+		if !writers[0].started {
+			err := writers[0].write(gram)
+			if err != nil {
+				return err
 			}
 
-			if len(ngram) < b.ngramSize {
-				ngram = append(ngram, gram)
+			pending = append(pending, gram)
+			if !writers[0].started {
+				return nil
+			}
 
-				if len(ngram) < b.ngramSize {
-					continue
+			for _, w := range writers[1:] {
+				for _, gram := range pending {
+					err := w.write(gram)
+					if err != nil {
+						return err
+					}
 				}
-			} else {
-				ngrams <- gram
+			}
+			pending = nil
+			return nil
+		}
 
-				copy(ngram[0:], ngram[1:])
-				ngram[b.ngramSize-1] = gram
+		for _, w := range writers {
+			err := w.write(gram)
+			if err != nil {
+				return err
 			}
+		}
+
+		return nil
+	}, wrote
+}
+
+// ngramWriter writes ngrams of a single size to a chain. Each ngram is linked
+// to the tag that follows it, and each tag is linked to the ngram it ends.
+type ngramWriter struct {
+	chain markov.WriteChain
+	size  int
+	ngram []string
 
-			ngrams <- strings.Join(ngram, " ")
+	started bool
+	last    int
+}
+
+func (w *ngramWriter) write(gram string) error {
+	if w.size == 1 {
+		return w.add(gram)
+	}
+
+	if len(w.ngram) < w.size {
+		w.ngram = append(w.ngram, gram)
+
+		if len(w.ngram) < w.size {
+			return nil
+		}
+	} else {
+		err := w.add(gram)
+		if err != nil {
+			return err
 		}
-	}()
 
-	return ngrams
+		copy(w.ngram[0:], w.ngram[1:])
+		w.ngram[w.size-1] = gram
+	}
+
+	return w.add(strings.Join(w.ngram, " "))
+}
+
+func (w *ngramWriter) add(value string) error {
+	id, err := w.chain.Add(value)
+	if err != nil {
+		return err
+	}
+
+	if w.started {
+		err = w.chain.Relate(w.last, id, 1)
+		if err != nil {
+			return err
+		}
+	}
+
+	w.started = true
+	w.last = id
+	return nil
+}
+
+// lockedChain serializes writes to a chain that's fed from several
+// goroutines.
+type lockedChain struct {
+	mu    sync.Mutex
+	chain markov.WriteChain
+}
+
+func (c *lockedChain) Add(value interface{}) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.chain.Add(value)
+}
+
+func (c *lockedChain) Relate(parent, child int, delta int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.chain.Relate(parent, child, delta)
 }
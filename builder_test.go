@@ -1,6 +1,7 @@
 package randtxt
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"strings"
@@ -41,6 +42,72 @@ func TestUnigramBuilder(t *testing.T) {
 	}
 }
 
+func TestSentenceMarkersBuilder(t *testing.T) {
+	chain := markov.NewMemoryChain(100)
+	b := NewModelBuilder(chain, 2)
+	b.SentenceMarkers = true
+
+	err := b.Feed(sliceFeed(
+		Tag{Text: "Hello", POS: "UH"},
+		Tag{Text: ".", POS: "."},
+		ParagraphBreakTag,
+		Tag{Text: "Bye", POS: "UH"},
+	))
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+
+	expected := []string{
+		"<s> hello/UH",
+		"hello/UH ./.",
+		"./. </s>",
+		"</s> <p>",
+		"<p> <s>",
+		"<s> bye/UH",
+		"bye/UH </s>",
+	}
+
+	for _, ngram := range expected {
+		_, err := chain.Find(ngram)
+		if err != nil {
+			t.Errorf("%q: got error: %v", ngram, err)
+		}
+	}
+}
+
+func TestFeedContext(t *testing.T) {
+	chain := markov.NewMemoryChain(100)
+	b := NewModelBuilder(chain, 3)
+
+	// Never closed.
+	tags := make(chan Tag)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		tags <- Tag{Text: "A", POS: "NNP"}
+		cancel()
+	}()
+
+	err := b.FeedContext(ctx, tags)
+	if err != context.Canceled {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+}
+
+func sliceFeed(tags ...Tag) <-chan Tag {
+	c := make(chan Tag)
+
+	go func() {
+		defer close(c)
+
+		for _, tag := range tags {
+			c <- tag
+		}
+	}()
+
+	return c
+}
+
 func tagFeed(n int) <-chan Tag {
 	c := make(chan Tag)
 
@@ -84,3 +151,45 @@ func describeID(chain markov.Chain, id int) string {
 
 	return value.(string) + ":" + strings.Join(linkDesc, " ")
 }
+
+func TestBackoffBuilder(t *testing.T) {
+	chain := markov.NewMemoryChain(100)
+	b := NewModelBuilder(chain, 3)
+	b.Backoff = true
+
+	err := b.Feed(tagFeed(100))
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+
+	actual := describeID(chain, 0)
+	expected := "A/NNP B/NNP C/NNP:D/NNP-1.00"
+	if actual != expected {
+		t.Errorf("root: got %q, want %q", actual, expected)
+	}
+
+	for _, ngram := range []string{"B/NNP C/NNP", "C/NNP"} {
+		id, err := chain.Find(ngram)
+		if err != nil {
+			t.Errorf("%q: got error: %v", ngram, err)
+			continue
+		}
+
+		links, err := chain.Links(id)
+		if err != nil {
+			t.Fatalf("got error: %v", err)
+		}
+
+		found := false
+		for _, link := range links {
+			val, _ := chain.Get(link.ID)
+			if val == "D/NNP" {
+				found = true
+			}
+		}
+
+		if !found {
+			t.Errorf("%q is not linked to D/NNP", ngram)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package randtxt
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/pboyd/markov"
+)
+
+// walkChain calls fn for every string value in the chain, in a stable order.
+// Non-string values and metadata are skipped. Stops early if the context is cancelled.
+func walkChain(ctx context.Context, chain markov.Chain, fn func(id int, value string) error) error {
+	return walkValues(ctx, chain, func(id int, value string) error {
+		if isMetadata(value) {
+			return nil
+		}
+		return fn(id, value)
+	})
+}
+
+// walkValues is like walkChain, but includes the metadata.
+func walkValues(ctx context.Context, chain markov.Chain, fn func(id int, value string) error) error {
+	if ic, ok := chain.(markov.IterativeChain); ok {
+		id := 0
+		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			raw, err := ic.Get(id)
+			if err != nil {
+				return err
+			}
+
+			if value, ok := raw.(string); ok {
+				err = fn(id, value)
+				if err != nil {
+					return err
+				}
+			}
+
+			id, err = ic.Next(id)
+			if err == markov.ErrBrokenChain {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	walker := markov.IterativeWalker(chain)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		raw, err := walker.Next()
+		if err == markov.ErrBrokenChain {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		value, ok := raw.(string)
+		if !ok {
+			continue
+		}
+
+		id, err := chain.Find(value)
+		if err != nil {
+			return err
+		}
+
+		err = fn(id, value)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// ngramIDs returns the ID of every ngram in the chain with "size" tags.
+func ngramIDs(ctx context.Context, chain markov.Chain, size int) ([]int, error) {
+	ids := []int{}
+	err := walkChain(ctx, chain, func(id int, value string) error {
+		if strings.Count(value, " ")+1 == size {
+			ids = append(ids, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("chain has no ngrams of size %d", size)
+	}
+
+	return ids, nil
+}
+
+// seedIDs returns the IDs of the ngrams a model can start from.
+//
+// If the chain has sentence markers the seeds are the ngrams that end with
+// SentenceStartTag, so the next tag begins a sentence. Otherwise any ngram with
+// "size" tags can be used.
+func seedIDs(ctx context.Context, chain markov.Chain, size int) ([]int, error) {
+	markers, err := hasSentenceMarkers(chain)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := ngramIDs(ctx, chain, size)
+	if err != nil || !markers {
+		return ids, err
+	}
+
+	suffix := SentenceStartTag.String()
+	starts := make([]int, 0, len(ids))
+	for _, id := range ids {
+		raw, err := chain.Get(id)
+		if err != nil {
+			return nil, err
+		}
+
+		if strings.HasSuffix(raw.(string), suffix) {
+			starts = append(starts, id)
+		}
+	}
+
+	if len(starts) == 0 {
+		return ids, nil
+	}
+
+	return starts, nil
+}
+
+// hasSentenceMarkers tests if the chain was built with
+// ModelBuilder.SentenceMarkers.
+func hasSentenceMarkers(chain markov.Chain) (bool, error) {
+	_, err := chain.Find(SentenceStartTag.String())
+	if err == markov.ErrNotFound {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// randomSeed picks one of the ngrams in "ids".
+func randomSeed(chain markov.Chain, ids []int, r *rand.Rand) (string, error) {
+	raw, err := chain.Get(ids[r.Intn(len(ids))])
+	if err != nil {
+		return "", err
+	}
+
+	return raw.(string), nil
+}
+
+func newRand(r *rand.Rand) *rand.Rand {
+	if r != nil {
+		return r
+	}
+
+	return rand.New(rand.NewSource(time.Now().UnixNano()))
+}
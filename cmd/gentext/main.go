@@ -6,21 +6,47 @@ import (
 	"io"
 	"math/rand"
 	"os"
+	"strings"
 
 	"github.com/pboyd/markov"
 	"github.com/pboyd/randtxt"
 )
 
 var (
-	source string
-	count  int
-	seed   int
+	source   string
+	count    int
+	seed     int
+	sampling randtxt.Sampling
+	words    int
+	chars    int
+	ellipsis bool
+	start    string
+	index    string
+	keywords string
+	strength float64
+	require  string
+	maxTags  int
+	tagSet   string
 )
 
 func init() {
 	flag.StringVar(&source, "chain", "", "path to the chain file")
 	flag.IntVar(&count, "count", 1, "number of paragraphs to generate")
 	flag.IntVar(&seed, "seed", 0, "random seed")
+	flag.Float64Var(&sampling.Temperature, "temperature", 1, "scale probabilities (below 1 is safer, above 1 is wilder)")
+	flag.IntVar(&sampling.TopK, "top-k", 0, "only sample from the k likeliest words (0 for no limit)")
+	flag.Float64Var(&sampling.TopP, "top-p", 0, "only sample from the likeliest words whose probability adds up to p (0 for no limit)")
+	flag.BoolVar(&sampling.Greedy, "greedy", false, "always choose the likeliest word")
+	flag.IntVar(&words, "words", 0, "generate text with at most this many words instead of paragraphs")
+	flag.IntVar(&chars, "chars", 0, "generate text with at most this many characters instead of paragraphs")
+	flag.BoolVar(&ellipsis, "ellipsis", false, "end text that had to be cut off with an ellipsis")
+	flag.StringVar(&start, "start", "", "phrase to start the text with")
+	flag.StringVar(&index, "index", "", "path to the word index (defaults to the chain path with a .idx extension, if it exists)")
+	flag.StringVar(&keywords, "keywords", "", "comma separated words to steer the text toward")
+	flag.Float64Var(&strength, "strength", 5, "how strongly to steer toward -keywords")
+	flag.StringVar(&require, "require", "", "comma separated words that every paragraph must contain")
+	flag.IntVar(&maxTags, "max-tags", 100, "maximum number of words and punctuation marks in a paragraph with -require")
+	flag.StringVar(&tagSet, "tagset", "", "name of the tagset the chain was built with (defaults to the one recorded in the chain)")
 	flag.Parse()
 }
 
@@ -29,7 +55,6 @@ func main() {
 		seed = os.Getpid()
 		fmt.Fprintf(os.Stderr, "-seed=%d\n", seed)
 	}
-	rand.Seed(int64(seed))
 
 	if source == "" {
 		fmt.Fprintf(os.Stderr, "error: chain is required\n")
@@ -50,15 +75,58 @@ func main() {
 		os.Exit(1)
 	}
 
-	gen, err := randtxt.NewGenerator(chain)
+	gen, err := randtxt.NewGenerator(chain, rand.New(rand.NewSource(int64(seed))))
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "invalid chain: %v\n", err)
 		os.Exit(2)
 	}
+	if tagSet != "" {
+		ts, ok := randtxt.LookupTagSet(tagSet)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "unknown tagset %q (have %s)\n", tagSet, strings.Join(randtxt.TagSets(), ", "))
+			os.Exit(1)
+		}
+		gen.TagSet = ts
+	}
+	gen.Sampling = sampling
+	if keywords != "" {
+		gen.Steering = randtxt.Steering{
+			Keywords: strings.Split(keywords, ","),
+			Strength: strength,
+		}
+	}
+	if ellipsis {
+		gen.Ellipsis = ellipsisTag(gen.TagSet)
+	}
+
+	gen.Index, err = readIndex(chain)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to read index: %v\n", err)
+		os.Exit(1)
+	}
+
+	if start != "" {
+		err := gen.StartWith(start)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "unable to start with %q: %v\n", start, err)
+			os.Exit(2)
+		}
+	}
 
 	for i := 0; i < count; i++ {
 		var err error
-		err = gen.WriteParagraph(os.Stdout, 3, 6)
+		switch {
+		case require != "":
+			var text string
+			text, err = gen.ConstrainedParagraph(strings.Split(require, ","), maxTags)
+			io.WriteString(os.Stdout, text)
+		case words > 0:
+			err = gen.WriteWords(os.Stdout, words)
+		case chars > 0:
+			err = gen.WriteChars(os.Stdout, chars)
+		default:
+			err = gen.WriteParagraph(os.Stdout, 3, 6)
+		}
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "unable to generate paragraph: %v\n", err)
 			os.Exit(2)
@@ -70,3 +138,38 @@ func main() {
 	}
 	io.WriteString(os.Stdout, "\n")
 }
+
+// ellipsisTag returns an ellipsis tagged with the punctuation POS of the
+// tagset.
+func ellipsisTag(ts randtxt.TagSet) randtxt.Tag {
+	if ts == randtxt.UniversalTagSet {
+		return randtxt.Tag{Text: "...", POS: "PUNCT"}
+	}
+
+	return randtxt.Tag{Text: "...", POS: ":"}
+}
+
+// readIndex reads the word index for the chain. Returns nil if the index
+// wasn't given and there isn't one next to the chain.
+func readIndex(chain markov.Chain) (*randtxt.Index, error) {
+	path := index
+	if path == "" {
+		path = source + ".idx"
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return nil, nil
+		}
+	}
+
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	ix, err := randtxt.ReadIndex(fh)
+	if err != nil {
+		return nil, err
+	}
+
+	return ix, ix.Verify(chain)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pboyd/markov"
+	"github.com/pboyd/randtxt"
+)
+
+var (
+	source      string
+	output      string
+	importPath  string
+	fallbackPOS string
+)
+
+func init() {
+	flag.StringVar(&source, "chain", "", "path to the chain file")
+	flag.StringVar(&output, "o", "", "path to the ARPA output file (defaults to stdout)")
+	flag.StringVar(&importPath, "import", "", "path to an ARPA file to convert to a chain, instead of exporting the chain (back-off weights are dropped)")
+	flag.StringVar(&fallbackPOS, "pos", "", "POS for tokens in the -import file that don't have one")
+	flag.Parse()
+}
+
+func main() {
+	if source == "" {
+		fmt.Fprintf(os.Stderr, "error: -chain is required\n")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	if importPath != "" {
+		err := importARPA(importPath, source)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "unable to import %s: %v\n", importPath, err)
+			os.Exit(2)
+		}
+		return
+	}
+
+	fh, err := os.Open(source)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "file error (%s): %v\n", source, err)
+		os.Exit(1)
+	}
+	defer fh.Close()
+
+	chain, err := markov.ReadDiskChain(fh)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to read chain: %v\n", err)
+		os.Exit(1)
+	}
+
+	var out io.Writer = os.Stdout
+	if output != "" {
+		outFh, err := os.Create(output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "file error (%s): %v\n", output, err)
+			os.Exit(1)
+		}
+		defer outFh.Close()
+		out = outFh
+	}
+
+	err = randtxt.WriteARPA(out, chain)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to write ARPA file: %v\n", err)
+		os.Exit(2)
+	}
+}
+
+// importARPA reads the ARPA file at "path" and writes it as a chain to
+// "chainPath".
+func importARPA(path, chainPath string) error {
+	fh, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	model, err := randtxt.ReadARPA(fh, fallbackPOS)
+	if err != nil {
+		return err
+	}
+
+	if model.HasBackoffWeights() {
+		fmt.Fprintf(os.Stderr, "warning: %s has back-off weights, they can't be stored in the chain and will be dropped\n", path)
+	}
+
+	memoryChain := markov.NewMemoryChain(0)
+	err = model.WriteChain(memoryChain)
+	if err != nil {
+		return err
+	}
+
+	outFh, err := os.Create(chainPath)
+	if err != nil {
+		return err
+	}
+	defer outFh.Close()
+
+	diskChain, err := markov.NewDiskChainWriter(outFh)
+	if err != nil {
+		return err
+	}
+
+	return markov.Copy(diskChain, memoryChain)
+}
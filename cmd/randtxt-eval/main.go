@@ -0,0 +1,242 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pboyd/markov"
+	"github.com/pboyd/randtxt"
+)
+
+var (
+	orders    string
+	train     float64
+	backoff   bool
+	markers   bool
+	smoothing string
+	addK      float64
+	tagSet    string
+)
+
+// errNothingScored is returned by evaluate when none of the test tags could
+// be scored.
+var errNothingScored = errors.New("no tags were scored")
+
+func init() {
+	flag.StringVar(&orders, "n", "1,2,3,4", "comma separated ngram sizes to compare")
+	flag.Float64Var(&train, "train", 0.9, "fraction of the corpus to build the models from, the rest is held out for testing")
+	flag.BoolVar(&backoff, "backoff", true, "build the models with -backoff")
+	flag.BoolVar(&markers, "markers", false, "build the models with -markers")
+	flag.StringVar(&smoothing, "smoothing", "", "smooth the probabilities (add-k or kneser-ney)")
+	flag.Float64Var(&addK, "k", 1, "amount to add to each count for -smoothing add-k")
+	flag.StringVar(&tagSet, "tagset", "penn", "name of the tagset the source is tagged with")
+	flag.Parse()
+}
+
+func main() {
+	sources := flag.Args()
+	if len(sources) == 0 {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] source [source]...\n", os.Args[0])
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	sizes, err := parseOrders(orders)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -n: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch smoothing {
+	case "", "add-k", "kneser-ney":
+	default:
+		fmt.Fprintf(os.Stderr, "unknown -smoothing %q\n", smoothing)
+		os.Exit(1)
+	}
+
+	ts, ok := randtxt.LookupTagSet(tagSet)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown tagset %q (have %s)\n", tagSet, strings.Join(randtxt.TagSets(), ", "))
+		os.Exit(1)
+	}
+
+	if train <= 0 || train >= 1 {
+		fmt.Fprintf(os.Stderr, "-train must be between 0 and 1\n")
+		os.Exit(1)
+	}
+
+	var corpus []randtxt.Tag
+	for _, source := range sources {
+		tags, err := readTSV(source)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "file error (%s): %v\n", source, err)
+			os.Exit(1)
+		}
+		corpus = append(corpus, tags...)
+	}
+
+	trainTags, testTags := split(corpus, train, ts)
+	if len(trainTags) == 0 || len(testTags) == 0 {
+		fmt.Fprintf(os.Stderr, "corpus is too small to split\n")
+		os.Exit(1)
+	}
+
+	fmt.Printf("train: %d tags, test: %d tags\n\n", len(trainTags), len(testTags))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "n\tperplexity\tcoverage\tfull context\tadjusted perplexity")
+
+	best, bestPerplexity := 0, math.Inf(1)
+
+	for _, n := range sizes {
+		r, err := evaluate(trainTags, testTags, n, ts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error evaluating n=%d: %v\n", n, err)
+			os.Exit(2)
+		}
+
+		fmt.Fprintf(w, "%d\t%.3f\t%.1f%%\t%.1f%%\t%.3f\n", n, r.perplexity, r.coverage*100, r.fullContext*100, r.adjusted)
+
+		if r.adjusted < bestPerplexity {
+			best, bestPerplexity = n, r.adjusted
+		}
+	}
+	w.Flush()
+
+	fmt.Printf("\nrecommended: -n %d\n", best)
+}
+
+func parseOrders(s string) ([]int, error) {
+	var sizes []int
+	for _, field := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, err
+		}
+
+		if n < 1 {
+			return nil, fmt.Errorf("ngram size %d is less than 1", n)
+		}
+
+		sizes = append(sizes, n)
+	}
+
+	return sizes, nil
+}
+
+func readTSV(path string) ([]randtxt.Tag, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	return randtxt.ReadTSV(fh)
+}
+
+// split divides the corpus at the first sentence boundary after "fraction" of
+// the tags.
+func split(corpus []randtxt.Tag, fraction float64, ts randtxt.TagSet) ([]randtxt.Tag, []randtxt.Tag) {
+	i := int(float64(len(corpus)) * fraction)
+	for i < len(corpus) && !randtxt.EndsSentence(ts, corpus[i]) {
+		i++
+	}
+
+	if i < len(corpus) {
+		i++
+	}
+
+	return corpus[:i], corpus[i:]
+}
+
+type result struct {
+	// perplexity is the perplexity of the tags the model has seen.
+	perplexity float64
+
+	// coverage is the fraction of tags that the model has seen after
+	// their context. Tags whose context isn't in the model at all count
+	// as unseen.
+	coverage float64
+
+	// fullContext is the fraction of tags that were predicted from a
+	// full size context.
+	fullContext float64
+
+	// adjusted is the perplexity of every tag, with the unseen tags
+	// given a floor probability. See randtxt.Score.Perplexity.
+	adjusted float64
+}
+
+// evaluate builds a model from "trainTags" and scores "testTags" with it.
+func evaluate(trainTags, testTags []randtxt.Tag, n int, ts randtxt.TagSet) (result, error) {
+	chain := markov.NewMemoryChain(0)
+	builder := randtxt.NewModelBuilder(chain, n)
+	builder.TagSet = ts
+	builder.Backoff = backoff
+	builder.SentenceMarkers = markers
+	builder.Counts = randtxt.NewCounts()
+
+	err := builder.Feed(feed(trainTags))
+	if err != nil {
+		return result{}, err
+	}
+
+	scorer, err := randtxt.NewScorer(chain)
+	if err != nil {
+		return result{}, err
+	}
+
+	switch smoothing {
+	case "add-k":
+		scorer.Estimator = randtxt.NewAddK(builder.Counts, addK)
+	case "kneser-ney":
+		scorer.Estimator = randtxt.NewKneserNey(builder.Counts)
+	}
+
+	score, err := scorer.Score(testTags)
+	if err != nil {
+		return result{}, err
+	}
+
+	if len(score.Tokens) == 0 {
+		return result{}, errNothingScored
+	}
+
+	full := 0
+	for _, token := range score.Tokens {
+		if token.Order == n && !token.Unseen {
+			full++
+		}
+	}
+
+	perplexity := math.Inf(1)
+	if score.Count > 0 {
+		perplexity = math.Exp(-score.LogLikelihood / float64(score.Count))
+	}
+
+	return result{
+		perplexity:  perplexity,
+		coverage:    score.Coverage(),
+		fullContext: float64(full) / float64(len(score.Tokens)),
+		adjusted:    score.Perplexity(),
+	}, nil
+}
+
+func feed(tags []randtxt.Tag) <-chan randtxt.Tag {
+	c := make(chan randtxt.Tag)
+
+	go func() {
+		defer close(c)
+		for _, tag := range tags {
+			c <- tag
+		}
+	}()
+
+	return c
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/pboyd/markov"
+	"github.com/pboyd/randtxt"
+)
+
+var (
+	source string
+	output string
+)
+
+func init() {
+	flag.StringVar(&source, "chain", "", "path to the chain file to migrate")
+	flag.StringVar(&output, "o", "", "path to the migrated chain file")
+	flag.Parse()
+}
+
+func main() {
+	if source == "" || output == "" {
+		fmt.Fprintf(os.Stderr, "error: -chain and -o are required\n")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	if source == output {
+		fmt.Fprintf(os.Stderr, "error: -o must be a different file than -chain\n")
+		os.Exit(1)
+	}
+
+	fh, err := os.Open(source)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "file error (%s): %v\n", source, err)
+		os.Exit(1)
+	}
+	defer fh.Close()
+
+	chain, err := markov.ReadDiskChain(fh)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to read chain: %v\n", err)
+		os.Exit(1)
+	}
+
+	memoryChain := markov.NewMemoryChain(0)
+	err = randtxt.MigrateChain(memoryChain, chain)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to migrate chain: %v\n", err)
+		os.Exit(2)
+	}
+
+	outFh, err := os.Create(output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "file error (%s): %v\n", output, err)
+		os.Exit(1)
+	}
+	defer outFh.Close()
+
+	diskChain, err := markov.NewDiskChainWriter(outFh)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error writing chain: %v\n", err)
+		os.Exit(2)
+	}
+
+	err = markov.Copy(diskChain, memoryChain)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error writing chain: %v\n", err)
+		os.Exit(2)
+	}
+
+	// Counts are converted when they're read.
+	if exists(source + ".counts") {
+		err := migrateCounts(source+".counts", output+".counts")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error migrating counts: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	if exists(source + ".idx") {
+		// The IDs in the index are from the chain on disk.
+		err := writeIndex(output+".idx", diskChain)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error writing index: %v\n", err)
+			os.Exit(2)
+		}
+	}
+}
+
+func migrateCounts(path, outputPath string) error {
+	fh, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	counts, err := randtxt.ReadCounts(fh)
+	if err != nil {
+		return err
+	}
+
+	outFh, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer outFh.Close()
+
+	_, err = counts.WriteTo(outFh)
+	if err != nil {
+		return err
+	}
+
+	return outFh.Close()
+}
+
+func writeIndex(path string, chain markov.Chain) error {
+	ix, err := randtxt.BuildIndex(chain)
+	if err != nil {
+		return err
+	}
+
+	fh, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	_, err = ix.WriteTo(fh)
+	if err != nil {
+		return err
+	}
+
+	return fh.Close()
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
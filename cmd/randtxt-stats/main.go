@@ -14,11 +14,19 @@ import (
 var (
 	source      string
 	entropyPath string
+	scorePath   string
+	smoothing   string
+	countsPath  string
+	addK        float64
 )
 
 func init() {
 	flag.StringVar(&source, "chain", "", "path to the chain file")
 	flag.StringVar(&entropyPath, "entropy", "", "path to the entropy output file")
+	flag.StringVar(&scorePath, "score", "", "path to a tagged TSV file to score against the chain")
+	flag.StringVar(&smoothing, "smoothing", "", "smooth the probabilities for -score (add-k or kneser-ney)")
+	flag.StringVar(&countsPath, "counts", "", "path to the ngram counts for -smoothing (defaults to the chain path with a .counts extension)")
+	flag.Float64Var(&addK, "k", 1, "amount to add to each count for -smoothing add-k")
 	flag.Parse()
 }
 
@@ -29,8 +37,8 @@ func main() {
 		os.Exit(1)
 	}
 
-	if entropyPath == "" {
-		fmt.Fprintf(os.Stderr, "error: -entropy is required\n")
+	if entropyPath == "" && scorePath == "" {
+		fmt.Fprintf(os.Stderr, "error: -entropy or -score is required\n")
 		flag.PrintDefaults()
 		os.Exit(1)
 	}
@@ -48,6 +56,15 @@ func main() {
 		os.Exit(1)
 	}
 
+	if scorePath != "" {
+		err := writeScore(os.Stdout, chain, scorePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "unable to score %s: %v\n", scorePath, err)
+			os.Exit(2)
+		}
+		return
+	}
+
 	outFh, err := os.Create(entropyPath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "unable to create entropy output file: %v\n", err)
@@ -67,7 +84,7 @@ func main() {
 // each step. writeEntropy stops when the mean of the generated values settles
 // down.
 func writeEntropy(w io.Writer, chain markov.Chain) error {
-	model, err := randtxt.NewModel(chain, "")
+	model, err := randtxt.NewModel(chain, "", nil)
 	if err != nil {
 		return err
 	}
@@ -102,6 +119,84 @@ func writeEntropy(w io.Writer, chain markov.Chain) error {
 	return nil
 }
 
+// writeScore scores the tagged text in "path" against the chain. It writes
+// the log probability of each tag, followed by a summary.
+func writeScore(w io.Writer, chain markov.Chain, path string) error {
+	fh, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	tags, err := randtxt.ReadTSV(fh)
+	if err != nil {
+		return err
+	}
+
+	scorer, err := randtxt.NewScorer(chain)
+	if err != nil {
+		return err
+	}
+
+	if smoothing != "" {
+		scorer.Estimator, err = readEstimator()
+		if err != nil {
+			return err
+		}
+	}
+
+	score, err := scorer.Score(tags)
+	if err != nil {
+		return err
+	}
+
+	for _, ts := range score.Tokens {
+		switch {
+		case ts.Unseen:
+			fmt.Fprintf(w, "%s\t%s\tunseen\n", ts.Tag.Text, ts.Tag.POS)
+		case ts.Order == 0:
+			fmt.Fprintf(w, "%s\t%s\tnot scored\n", ts.Tag.Text, ts.Tag.POS)
+		default:
+			fmt.Fprintf(w, "%s\t%s\t%f\t(order %d)\n", ts.Tag.Text, ts.Tag.POS, ts.LogProbability, ts.Order)
+		}
+	}
+
+	fmt.Fprintf(w, "\ntags: %d scored, %d unseen, %d total\n", score.Count, score.Unseen, len(score.Tokens))
+	fmt.Fprintf(w, "log likelihood: %f\n", score.LogLikelihood)
+	fmt.Fprintf(w, "perplexity: %f\n", score.Perplexity())
+
+	return nil
+}
+
+// readEstimator reads the ngram counts and returns the Estimator selected
+// with -smoothing.
+func readEstimator() (randtxt.Estimator, error) {
+	path := countsPath
+	if path == "" {
+		path = source + ".counts"
+	}
+
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	counts, err := randtxt.ReadCounts(fh)
+	if err != nil {
+		return nil, err
+	}
+
+	switch smoothing {
+	case "add-k":
+		return randtxt.NewAddK(counts, addK), nil
+	case "kneser-ney":
+		return randtxt.NewKneserNey(counts), nil
+	default:
+		return nil, fmt.Errorf("unknown smoothing %q", smoothing)
+	}
+}
+
 func singleEntropy(p []randtxt.TagProbability) float64 {
 	sum := 0.0
 	for _, tp := range p {
@@ -1,10 +1,8 @@
 package main
 
 import (
-	"bufio"
 	"flag"
 	"fmt"
-	"io"
 	"os"
 	"strings"
 
@@ -13,17 +11,38 @@ import (
 )
 
 var (
-	output string
-	update bool
-	onDisk bool
-	n      int
+	output  string
+	update  bool
+	onDisk  bool
+	n       int
+	backoff bool
+	markers bool
+	index   bool
+	counts  bool
+	tagSet  string
+	mapping string
+	format  string
+	posCol  string
 )
 
+// mappers are the tag mappings that can be given with -map.
+var mappers = map[string]func() *randtxt.TagMapper{
+	"penn-universal": randtxt.PennToUniversal,
+}
+
 func init() {
 	flag.StringVar(&output, "chain", "", "path the the output chain file")
 	flag.BoolVar(&update, "update", false, "update the output file instead of overwriting it")
 	flag.BoolVar(&onDisk, "disk", false, "write the chain directly to disk")
 	flag.IntVar(&n, "n", 3, "ngram size")
+	flag.BoolVar(&markers, "markers", false, "mark the start and end of each sentence")
+	flag.BoolVar(&backoff, "backoff", false, "also write every smaller ngram size, so generation can back off to them")
+	flag.BoolVar(&index, "index", false, "write a word index next to the chain (with a .idx extension)")
+	flag.BoolVar(&counts, "counts", false, "write the ngram counts next to the chain (with a .counts extension), for smoothed scoring")
+	flag.StringVar(&tagSet, "tagset", "", "name of the tagset the source is tagged with (default universal for conllu with -pos upos, otherwise penn)")
+	flag.StringVar(&mapping, "map", "", "convert the source tags to -tagset (penn-universal)")
+	flag.StringVar(&format, "format", "tsv", "format of the source files: tsv (word<TAB>POS), underscore (word_POS), slash (word/POS) or conllu")
+	flag.StringVar(&posCol, "pos", "upos", "column with the POS tags in conllu files: upos or xpos")
 	flag.Parse()
 }
 
@@ -39,15 +58,78 @@ func main() {
 		os.Exit(1)
 	}
 
-	tags := make([]<-chan randtxt.Tag, len(sources))
+	column := randtxt.UPOSColumn
+	switch posCol {
+	case "upos":
+	case "xpos":
+		column = randtxt.XPOSColumn
+	default:
+		fmt.Fprintf(os.Stderr, "unknown POS column %q\n", posCol)
+		os.Exit(1)
+	}
+
+	upos := format == "conllu" && column == randtxt.UPOSColumn
+	switch {
+	case tagSet == "" && upos:
+		tagSet = randtxt.UniversalTagSet.Name()
+	case tagSet == "":
+		tagSet = randtxt.PennTreebankTagSet.Name()
+	case upos && mapping == "" && tagSet != randtxt.UniversalTagSet.Name():
+		fmt.Fprintf(os.Stderr, "-pos upos reads Universal POS tags, use -tagset universal or -pos xpos\n")
+		os.Exit(1)
+	}
+
+	ts, ok := randtxt.LookupTagSet(tagSet)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown tagset %q (have %s)\n", tagSet, strings.Join(randtxt.TagSets(), ", "))
+		os.Exit(1)
+	}
+
+	var mapper *randtxt.TagMapper
+	if mapping != "" {
+		newMapper, ok := mappers[mapping]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "unknown tag mapping %q\n", mapping)
+			os.Exit(1)
+		}
+		mapper = newMapper()
+	}
+
+	readers := make([]randtxt.TagReader, len(sources))
 
 	for i, source := range sources {
-		var err error
-		tags[i], err = readTSV(source)
+		fh, err := os.Open(source)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "file error (%s): %v\n", source, err)
 			os.Exit(1)
 		}
+		defer fh.Close()
+
+		switch format {
+		case "tsv":
+			tr := randtxt.NewTSVReader(fh)
+			tr.Name = source
+			readers[i] = tr
+		case "underscore", "slash":
+			separator := '_'
+			if format == "slash" {
+				separator = '/'
+			}
+			ir := randtxt.NewInlineReader(fh, separator)
+			ir.Name = source
+			readers[i] = ir
+		case "conllu":
+			cr := randtxt.NewCoNLLUReader(fh, column)
+			cr.Name = source
+			readers[i] = cr
+		default:
+			fmt.Fprintf(os.Stderr, "unknown format %q\n", format)
+			os.Exit(1)
+		}
+
+		if mapper != nil {
+			readers[i] = mapper.Reader(readers[i])
+		}
 	}
 
 	diskChain, err := openOutputFile(output, update)
@@ -56,9 +138,29 @@ func main() {
 		os.Exit(1)
 	}
 
+	err = randtxt.CheckTagEncoding(diskChain.(markov.Chain))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v (see cmd/randtxt-migrate)\n", output, err)
+		os.Exit(1)
+	}
+
+	var ngramCounts *randtxt.Counts
+	if counts {
+		ngramCounts, err = openCounts(output+".counts", update)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "file error (%s.counts): %v\n", output, err)
+			os.Exit(1)
+		}
+	}
+
 	if onDisk {
 		builder := randtxt.NewModelBuilder(diskChain, n)
-		err := builder.Feed(tags...)
+		builder.TagSet = ts
+		builder.Backoff = backoff
+		builder.SentenceMarkers = markers
+		builder.Counts = ngramCounts
+		builder.Sources = sources
+		err := builder.FeedReaders(readers...)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "error building chain: %v\n", err)
 			os.Exit(2)
@@ -66,7 +168,12 @@ func main() {
 	} else {
 		memoryChain := &markov.MemoryChain{}
 		builder := randtxt.NewModelBuilder(memoryChain, n)
-		err := builder.Feed(tags...)
+		builder.TagSet = ts
+		builder.Backoff = backoff
+		builder.SentenceMarkers = markers
+		builder.Counts = ngramCounts
+		builder.Sources = sources
+		err := builder.FeedReaders(readers...)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "error building chain: %v\n", err)
 			os.Exit(2)
@@ -78,6 +185,80 @@ func main() {
 			os.Exit(2)
 		}
 	}
+
+	if counts {
+		err := writeCounts(output+".counts", ngramCounts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error writing counts: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	if index {
+		err := writeIndex(output+".idx", diskChain.(markov.Chain))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error writing index: %v\n", err)
+			os.Exit(2)
+		}
+	}
+}
+
+// openCounts reads the existing counts if the chain is being updated.
+// Otherwise it returns empty counts.
+func openCounts(path string, update bool) (*randtxt.Counts, error) {
+	if update {
+		exists, err := fileExists(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if exists {
+			fh, err := os.Open(path)
+			if err != nil {
+				return nil, err
+			}
+			defer fh.Close()
+
+			return randtxt.ReadCounts(fh)
+		}
+	}
+
+	return randtxt.NewCounts(), nil
+}
+
+func writeCounts(path string, counts *randtxt.Counts) error {
+	fh, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	_, err = counts.WriteTo(fh)
+	if err != nil {
+		return err
+	}
+
+	return fh.Close()
+}
+
+func writeIndex(path string, chain markov.Chain) error {
+	ix, err := randtxt.BuildIndex(chain)
+	if err != nil {
+		return err
+	}
+
+	fh, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	_, err = ix.WriteTo(fh)
+	if err != nil {
+		return err
+	}
+
+	return fh.Close()
 }
 
 func openOutputFile(path string, update bool) (markov.WriteChain, error) {
@@ -117,54 +298,3 @@ func fileExists(path string) (bool, error) {
 	// Some other error, probably an invalid path.
 	return false, err
 }
-
-func readTSV(path string) (<-chan randtxt.Tag, error) {
-	fh, err := os.Open(path)
-	if err != nil {
-		return nil, err
-	}
-
-	reader := bufio.NewReader(fh)
-
-	tags := make(chan randtxt.Tag)
-
-	go func() {
-		defer func() {
-			fh.Close()
-			close(tags)
-		}()
-
-		for {
-			line, err := reader.ReadString('\n')
-			if err != nil {
-				if err != io.EOF {
-					fmt.Fprintf(os.Stderr, "error reading file: %v", err)
-				}
-				break
-			}
-
-			tag := parseLine(line)
-			if tag.Text == "" || tag.POS == "" {
-				continue
-			}
-
-			tags <- tag
-		}
-	}()
-
-	return tags, nil
-}
-
-func parseLine(line string) randtxt.Tag {
-	line = strings.TrimSpace(line)
-
-	i := strings.IndexByte(line, '\t')
-	if i < 0 {
-		return randtxt.Tag{}
-	}
-
-	return randtxt.Tag{
-		Text: line[:i],
-		POS:  line[i+1:],
-	}
-}
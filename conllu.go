@@ -0,0 +1,152 @@
+package randtxt
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CoNLLUColumn is a column of a CoNLL-U file that has POS tags.
+type CoNLLUColumn int
+
+const (
+	// UPOSColumn has the Universal POS tags. See UniversalTagSet.
+	UPOSColumn CoNLLUColumn = 3
+
+	// XPOSColumn has the language specific POS tags, such as Penn
+	// Treebank tags for English treebanks.
+	XPOSColumn CoNLLUColumn = 4
+)
+
+// conlluColumns is the number of columns in each word line.
+const conlluColumns = 10
+
+// CoNLLUReader reads tags from a CoNLL-U file, the format of Universal
+// Dependencies treebanks.
+//
+// SentenceEndTag is returned after each sentence, and ParagraphBreakTag
+// before each sentence that starts a new paragraph or document, after the
+// first.
+//
+// A multi-word token, such as "don't", is read as the words it's split into
+// ("do" and "n't"), since only they have POS tags. Empty nodes are skipped.
+//
+// Words without an XPOS tag, which some treebanks leave out, are given their
+// UPOS tag instead. A word without either is an error.
+//
+// See https://universaldependencies.org/format.html
+type CoNLLUReader struct {
+	lines  *lineScanner
+	column CoNLLUColumn
+
+	// pending are tags that have been read but not returned.
+	pending []Tag
+
+	started      bool
+	inSentence   bool
+	newParagraph bool
+
+	// Name is the file name used in errors. It's optional.
+	Name string
+}
+
+// NewCoNLLUReader returns a CoNLLUReader that reads from "r". The POS of
+// each tag is taken from "column".
+func NewCoNLLUReader(r io.Reader, column CoNLLUColumn) *CoNLLUReader {
+	return &CoNLLUReader{
+		lines:  newLineScanner(r),
+		column: column,
+	}
+}
+
+// ReadTag returns the next tag.
+func (cr *CoNLLUReader) ReadTag() (Tag, error) {
+	if cr.column != UPOSColumn && cr.column != XPOSColumn {
+		return Tag{}, fmt.Errorf("invalid CoNLL-U column %d", cr.column)
+	}
+
+	for len(cr.pending) == 0 {
+		err := cr.readLine()
+		if err == io.EOF && cr.inSentence {
+			cr.inSentence = false
+			return SentenceEndTag, nil
+		}
+		if err != nil {
+			return Tag{}, err
+		}
+	}
+
+	tag := cr.pending[0]
+	cr.pending = cr.pending[1:]
+	return tag, nil
+}
+
+// readLine reads the next line and adds its tags to pending.
+func (cr *CoNLLUReader) readLine() error {
+	text, err := cr.lines.next(cr.Name)
+	if err != nil {
+		return err
+	}
+	text = strings.TrimRight(text, "\r")
+
+	if text == "" {
+		if cr.inSentence {
+			cr.pending = append(cr.pending, SentenceEndTag)
+			cr.inSentence = false
+		}
+		return nil
+	}
+
+	if strings.HasPrefix(text, "#") {
+		comment := strings.TrimSpace(text[1:])
+		if strings.HasPrefix(comment, "newpar") || strings.HasPrefix(comment, "newdoc") {
+			cr.newParagraph = true
+		}
+		return nil
+	}
+
+	fields := strings.Split(text, "\t")
+	if len(fields) != conlluColumns {
+		return cr.lines.errorf(cr.Name, "want %d fields, got %d", conlluColumns, len(fields))
+	}
+
+	// Multi-word token ranges ("1-2") and empty nodes ("1.1") don't have
+	// POS tags.
+	if strings.ContainsAny(fields[0], "-.") {
+		return nil
+	}
+
+	pos := fields[cr.column]
+	if isEmptyCoNLLU(pos) {
+		pos = fields[UPOSColumn]
+	}
+	if isEmptyCoNLLU(pos) {
+		return cr.lines.errorf(cr.Name, "word %q has no POS", fields[1])
+	}
+
+	if !cr.inSentence {
+		if cr.newParagraph && cr.started {
+			cr.pending = append(cr.pending, ParagraphBreakTag)
+		}
+		cr.newParagraph = false
+		cr.inSentence = true
+		cr.started = true
+	}
+
+	cr.pending = append(cr.pending, Tag{
+		Text: fields[1],
+		POS:  pos,
+	})
+
+	return nil
+}
+
+// isEmptyCoNLLU tests if a CoNLL-U field is unspecified.
+func isEmptyCoNLLU(field string) bool {
+	return field == "_" || field == ""
+}
+
+// ReadCoNLLU reads every tag with a CoNLLUReader.
+func ReadCoNLLU(r io.Reader, column CoNLLUColumn) ([]Tag, error) {
+	return readAllTags(NewCoNLLUReader(r, column))
+}
@@ -0,0 +1,131 @@
+package randtxt
+
+import (
+	"io"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pboyd/markov"
+)
+
+func TestReadCoNLLU(t *testing.T) {
+	cases := []struct {
+		column   CoNLLUColumn
+		expected string
+	}{
+		{
+			column:   UPOSColumn,
+			expected: "Welcome/INTJ ,/PUNCT Ion/PROPN ./PUNCT </s> Are/AUX you/PRON from/ADP your/PRON native/ADJ city/NOUN of/ADP Ephesus/PROPN ?/PUNCT </s> <p> And/CCONJ do/AUX the/DET Epidaurians/PROPN have/VERB contests/NOUN of/ADP rhapsodes/NOUN at/ADP the/DET festival/NOUN </s> <p> Ion/PROPN ,/PUNCT select/VERB for/ADP me/PRON passages/NOUN which/PRON relate/VERB to/ADP the/DET rhapsode/NOUN 's/PART art/NOUN ./PUNCT </s>",
+		},
+		{
+			column:   XPOSColumn,
+			expected: "Welcome/UH ,/, Ion/NNP ./. </s> Are/VBP you/PRP from/IN your/PRP$ native/JJ city/NN of/IN Ephesus/NNP ?/. </s> <p> And/CC do/VBP the/DT Epidaurians/NNPS have/VB contests/NNS of/IN rhapsodes/NNS at/IN the/DT festival/NN </s> <p> Ion/NNP ,/, select/VB for/IN me/PRP passages/NNS which/WDT relate/VBP to/TO the/DT rhapsode/NN 's/POS art/NN ./. </s>",
+		},
+	}
+
+	for _, c := range cases {
+		tags := readTestFile(t, "testfiles/ion/sample.conllu", func(r io.Reader) ([]Tag, error) {
+			return ReadCoNLLU(r, c.column)
+		})
+
+		raw := make([]string, len(tags))
+		for i, tag := range tags {
+			raw[i] = tag.String()
+		}
+
+		actual := strings.Join(raw, " ")
+		if actual != c.expected {
+			t.Errorf("column %d:\ngot  %s\nwant %s", c.column, actual, c.expected)
+		}
+	}
+}
+
+func TestReadCoNLLUErrors(t *testing.T) {
+	cases := []struct {
+		text   string
+		column CoNLLUColumn
+		err    string
+	}{
+		{
+			text:   "1\tWelcome\twelcome\tINTJ\tUH\t_\t0\troot\t_\t_\n",
+			column: 2,
+			err:    "invalid CoNLL-U column 2",
+		},
+		{
+			text:   "# text = Welcome\n1\tWelcome\twelcome\tINTJ\n",
+			column: UPOSColumn,
+			err:    "line 2: want 10 fields, got 4",
+		},
+		{
+			text:   "1\tWelcome\twelcome\t_\t_\t_\t0\troot\t_\t_\n",
+			column: XPOSColumn,
+			err:    `line 1: word "Welcome" has no POS`,
+		},
+	}
+
+	for i, c := range cases {
+		_, err := ReadCoNLLU(strings.NewReader(c.text), c.column)
+		if err == nil || err.Error() != c.err {
+			t.Errorf("%d: got error %v, want %q", i, err, c.err)
+		}
+	}
+}
+
+func TestReadCoNLLUWithoutXPOS(t *testing.T) {
+	xpos := readTestFile(t, "testfiles/ion/no-xpos.conllu", func(r io.Reader) ([]Tag, error) {
+		return ReadCoNLLU(r, XPOSColumn)
+	})
+	upos := readTestFile(t, "testfiles/ion/no-xpos.conllu", func(r io.Reader) ([]Tag, error) {
+		return ReadCoNLLU(r, UPOSColumn)
+	})
+
+	if !reflect.DeepEqual(xpos, upos) {
+		t.Errorf("got %v, want the UPOS tags %v", xpos, upos)
+	}
+}
+
+func TestCoNLLUSentenceBoundaries(t *testing.T) {
+	chain := markov.NewMemoryChain(0)
+	b := NewModelBuilder(chain, 2)
+	b.TagSet = UniversalTagSet
+	b.SentenceMarkers = true
+	err := b.Feed(sliceFeed(readTestFile(t, "testfiles/ion/sample.conllu", func(r io.Reader) ([]Tag, error) {
+		return ReadCoNLLU(r, UPOSColumn)
+	})...))
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	// The third sentence has no final punctuation, but still ends.
+	for _, ngram := range []string{"festival/NOUN </s>", "</s> <p>", "<p> <s>"} {
+		_, err := chain.Find(ngram)
+		if err != nil {
+			t.Errorf("%q: got error %v, want nil", ngram, err)
+		}
+	}
+
+	g, err := NewGenerator(chain, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("invalid chain: %v", err)
+	}
+
+	_, err = g.Paragraph(1, 3)
+	if err != nil {
+		t.Errorf("got error %v, want nil", err)
+	}
+
+	// A word after a SentenceEndTag starts a sentence, even without
+	// sentence markers.
+	prep := &tagPreparer{tagSet: UniversalTagSet}
+	var prepared []Tag
+	for _, tag := range []Tag{{Text: "Yes", POS: "INTJ"}, SentenceEndTag, {Text: "Well", POS: "INTJ"}} {
+		prepared = append(prepared, prep.add(tag)...)
+	}
+
+	expected := []Tag{{Text: "yes", POS: "INTJ"}, {Text: "well", POS: "INTJ"}}
+	if len(prepared) != len(expected) || prepared[0] != expected[0] || prepared[1] != expected[1] {
+		t.Errorf("got %v, want %v", prepared, expected)
+	}
+}
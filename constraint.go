@@ -0,0 +1,307 @@
+package randtxt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/bits"
+	"strings"
+
+	"github.com/pboyd/markov"
+)
+
+var (
+	// ErrUnsatisfiable is returned when no text within the length limit
+	// contains every required word.
+	ErrUnsatisfiable = errors.New("no text within the length limit contains every required word")
+
+	// ErrSearchLimit is returned when the search for text containing the
+	// required words gives up before finding an answer either way.
+	ErrSearchLimit = errors.New("search limit reached before finding the required words")
+)
+
+// maxConstraintSteps limits the number of tags the constrained search will
+// consider.
+const maxConstraintSteps = 1000000
+
+// ConstrainedSentence returns a sentence that contains every word in
+// "required" and has at most "maxTags" tags (words and punctuation).
+//
+// Words are matched by their text, regardless of case or part of speech.
+// Returns ErrUnsatisfiable if no such sentence exists in the chain.
+func (g *Generator) ConstrainedSentence(required []string, maxTags int) (string, error) {
+	return g.constrained(context.Background(), required, maxTags, false)
+}
+
+// ConstrainedParagraph is like ConstrainedSentence, but the text may contain
+// any number of sentences.
+func (g *Generator) ConstrainedParagraph(required []string, maxTags int) (string, error) {
+	return g.constrained(context.Background(), required, maxTags, true)
+}
+
+// ConstrainedSentenceContext is like ConstrainedSentence but stops and returns
+// the context's error if the context is cancelled first.
+func (g *Generator) ConstrainedSentenceContext(ctx context.Context, required []string, maxTags int) (string, error) {
+	return g.constrained(ctx, required, maxTags, false)
+}
+
+// ConstrainedParagraphContext is like ConstrainedParagraph but stops and
+// returns the context's error if the context is cancelled first.
+func (g *Generator) ConstrainedParagraphContext(ctx context.Context, required []string, maxTags int) (string, error) {
+	return g.constrained(ctx, required, maxTags, true)
+}
+
+func (g *Generator) constrained(ctx context.Context, required []string, maxTags int, paragraph bool) (string, error) {
+	if len(required) > 64 {
+		return "", fmt.Errorf("too many required words (%d), the limit is 64", len(required))
+	}
+
+	err := checkTagSet(g.metadata, g.TagSet)
+	if err != nil {
+		return "", err
+	}
+
+	words := make(map[string]uint64, len(required))
+	for i, word := range required {
+		words[strings.ToLower(word)] |= 1 << uint(i)
+	}
+
+	for word := range words {
+		found, err := g.hasWord(ctx, word)
+		if err != nil {
+			return "", err
+		}
+
+		if !found {
+			return "", ErrUnsatisfiable
+		}
+	}
+
+	starts, err := g.sentenceStarts(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	s := &constraintSearch{
+		ctx:       ctx,
+		g:         g,
+		words:     words,
+		all:       (uint64(1) << uint(len(required))) - 1,
+		paragraph: paragraph,
+		failed:    map[searchKey]int{},
+	}
+
+	// Try the starting points in a random order.
+	g.rand.Shuffle(len(starts), func(i, j int) {
+		starts[i], starts[j] = starts[j], starts[i]
+	})
+
+	for _, start := range starts {
+		tags, ok, err := s.search(strings.Split(start, " "), 0, maxTags)
+		if err != nil {
+			return "", err
+		}
+
+		if ok {
+			return g.joinTags(withoutBoundaries(tags)), nil
+		}
+	}
+
+	return "", ErrUnsatisfiable
+}
+
+// hasWord tests if any tag in the chain has the text "word".
+func (g *Generator) hasWord(ctx context.Context, word string) (bool, error) {
+	if g.Index != nil {
+		return len(g.Index.Word(word)) > 0, nil
+	}
+
+	errFound := errors.New("found")
+	err := walkChain(ctx, g.chain, func(id int, value string) error {
+		for _, gram := range strings.Split(value, " ") {
+			if strings.EqualFold(parseTag(gram).Text, word) {
+				return errFound
+			}
+		}
+		return nil
+	})
+
+	if err == errFound {
+		return true, nil
+	}
+
+	return false, err
+}
+
+// sentenceStarts returns the ngrams that a sentence can follow.
+func (g *Generator) sentenceStarts(ctx context.Context) ([]string, error) {
+	if g.markers {
+		ids, err := seedIDs(ctx, g.chain, g.size)
+		if err != nil {
+			return nil, err
+		}
+		return idValues(g.chain, ids)
+	}
+
+	var starts []string
+	err := walkChain(ctx, g.chain, func(id int, value string) error {
+		grams := strings.Split(value, " ")
+		if len(grams) == g.size && EndsSentence(g.TagSet, parseTag(grams[len(grams)-1])) {
+			starts = append(starts, value)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(starts) == 0 {
+		return nil, ErrUnsatisfiable
+	}
+
+	return starts, nil
+}
+
+func idValues(chain markov.Chain, ids []int) ([]string, error) {
+	values := make([]string, len(ids))
+	for i, id := range ids {
+		raw, err := chain.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = raw.(string)
+	}
+	return values, nil
+}
+
+// constraintSearch is a depth first search through the chain for text that
+// contains every required word.
+type constraintSearch struct {
+	ctx       context.Context
+	g         *Generator
+	words     map[string]uint64
+	all       uint64
+	paragraph bool
+	steps     int
+
+	// failed records the most tags that were available when a search
+	// from a state failed. Searching again from the same state with the
+	// same number of tags or fewer is pointless.
+	failed map[searchKey]int
+}
+
+type searchKey struct {
+	past      string
+	satisfied uint64
+}
+
+// search returns the tags that follow "past" to the end of a sentence,
+// containing every required word that hasn't been satisfied, in at most
+// "remaining" tags. Returns false if there aren't any.
+func (s *constraintSearch) search(past []string, satisfied uint64, remaining int) ([]Tag, bool, error) {
+	if bits.OnesCount64(s.all&^satisfied) > remaining {
+		return nil, false, nil
+	}
+
+	key := searchKey{past: strings.Join(past, " "), satisfied: satisfied}
+	if failed, ok := s.failed[key]; ok && remaining <= failed {
+		return nil, false, nil
+	}
+
+	s.steps++
+	if s.steps > maxConstraintSteps {
+		return nil, false, ErrSearchLimit
+	}
+
+	if err := s.ctx.Err(); err != nil {
+		return nil, false, err
+	}
+
+	candidates, err := s.candidates(past, satisfied)
+	if err != nil {
+		return nil, false, err
+	}
+
+	for _, gram := range candidates {
+		tag := parseTag(gram)
+
+		left := remaining
+		if !tag.IsBoundary() {
+			left--
+			if left < 0 {
+				continue
+			}
+		}
+
+		sat := satisfied | s.words[strings.ToLower(tag.Text)]
+
+		if s.g.endsSentence(tag) {
+			if sat == s.all {
+				return []Tag{tag}, true, nil
+			}
+
+			if !s.paragraph {
+				continue
+			}
+		}
+
+		rest, ok, err := s.search(shiftPast(past, gram), sat, left)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if ok {
+			return append([]Tag{tag}, rest...), true, nil
+		}
+	}
+
+	s.failed[key] = remaining
+	return nil, false, nil
+}
+
+// candidates returns the tags that can follow "past", in the order they
+// should be tried. Unsatisfied required words are tried first, the rest are
+// in a random order weighted by their probability.
+func (s *constraintSearch) candidates(past []string, satisfied uint64) ([]string, error) {
+	links, err := backoffLinks(s.g.chain, past)
+	if err != nil {
+		return nil, err
+	}
+
+	var wanted, rest []string
+
+	for len(links) > 0 {
+		link := Sampling{}.pick(links, s.g.rand)
+		for i := range links {
+			if links[i].ID == link.ID {
+				links = append(links[:i], links[i+1:]...)
+				break
+			}
+		}
+		normalize(links, 1-link.Probability)
+
+		raw, err := s.g.chain.Get(link.ID)
+		if err != nil {
+			return nil, err
+		}
+		gram := raw.(string)
+
+		if s.words[strings.ToLower(parseTag(gram).Text)]&^satisfied != 0 {
+			wanted = append(wanted, gram)
+		} else {
+			rest = append(rest, gram)
+		}
+	}
+
+	return append(wanted, rest...), nil
+}
+
+func withoutBoundaries(tags []Tag) []Tag {
+	words := make([]Tag, 0, len(tags))
+	for _, tag := range tags {
+		if !tag.IsBoundary() {
+			words = append(words, tag)
+		}
+	}
+	return words
+}
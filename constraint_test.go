@@ -0,0 +1,94 @@
+package randtxt
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestConstrainedSentence(t *testing.T) {
+	chain, close := testChain(t, "testfiles/ion/trigram.mkv")
+	defer close()
+
+	g, err := NewGenerator(chain, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("invalid chain: %v", err)
+	}
+
+	required := []string{"poet", "Homer"}
+
+	text, err := g.ConstrainedSentence(required, 40)
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	checkParagraph(t, text, 1, 1)
+	checkRequired(t, text, required)
+}
+
+func TestConstrainedParagraph(t *testing.T) {
+	chain, close := testChain(t, "testfiles/ion/trigram.mkv")
+	defer close()
+
+	g, err := NewGenerator(chain, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("invalid chain: %v", err)
+	}
+
+	required := []string{"rhapsode", "general", "lyre"}
+
+	text, err := g.ConstrainedParagraph(required, 80)
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	checkParagraph(t, text, 1, 10)
+	checkRequired(t, text, required)
+}
+
+func TestConstrainedUnsatisfiable(t *testing.T) {
+	chain, close := testChain(t, "testfiles/ion/trigram.mkv")
+	defer close()
+
+	g, err := NewGenerator(chain, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("invalid chain: %v", err)
+	}
+
+	cases := []struct {
+		required []string
+		maxTags  int
+	}{
+		{[]string{"purple"}, 40},
+		{[]string{"poet", "Homer"}, 2},
+	}
+
+	for _, c := range cases {
+		_, err := g.ConstrainedSentence(c.required, c.maxTags)
+		if err != ErrUnsatisfiable {
+			t.Errorf("%v in %d tags: got error %v, want %v", c.required, c.maxTags, err, ErrUnsatisfiable)
+		}
+	}
+}
+
+func checkRequired(t *testing.T, text string, required []string) {
+	t.Helper()
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !isWordRune(r)
+	})
+
+	for _, r := range required {
+		found := false
+		for _, w := range words {
+			if w == strings.ToLower(r) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			t.Errorf("%q doesn't contain %q", text, r)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package randtxt
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	countsHeader = "randtxt-counts"
+
+	// countsVersion is the version of the counts format. Version 1 was
+	// written before tags were escaped (see Tag.String).
+	countsVersion = 2
+)
+
+// Counts holds the number of times each tag followed each context in the
+// text a model was built from, for every context length up to the model's
+// ngram size. The smoothed estimators use it.
+//
+// Set ModelBuilder.Counts to record them while building a model. Counts for
+// a chain on disk can be saved next to it with WriteTo and loaded with
+// ReadCounts.
+type Counts struct {
+	mu   sync.Mutex
+	size int
+
+	// ngrams maps each context, as raw tags joined with spaces, to the
+	// number of times each raw tag followed it. The empty context holds
+	// the unigram counts.
+	ngrams map[string]map[string]int
+}
+
+// NewCounts returns an empty Counts.
+func NewCounts() *Counts {
+	return &Counts{
+		ngrams: map[string]map[string]int{},
+	}
+}
+
+// Size returns the longest context that has been counted.
+func (c *Counts) Size() int {
+	return c.size
+}
+
+// Count returns the number of times "next" followed "context".
+func (c *Counts) Count(context []Tag, next Tag) int {
+	return c.ngrams[joinRaw(context)][next.String()]
+}
+
+// add counts "next" following every suffix of "past", including the empty
+// one.
+func (c *Counts) add(past []string, next string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(past) > c.size {
+		c.size = len(past)
+	}
+
+	for start := 0; start <= len(past); start++ {
+		c.addOne(strings.Join(past[start:], " "), next, 1)
+	}
+}
+
+func (c *Counts) addOne(context, next string, n int) {
+	nexts := c.ngrams[context]
+	if nexts == nil {
+		nexts = map[string]int{}
+		c.ngrams[context] = nexts
+	}
+	nexts[next] += n
+}
+
+// WriteTo writes the counts to "w" in a format ReadCounts can read.
+func (c *Counts) WriteTo(w io.Writer) (int64, error) {
+	buf := bufio.NewWriter(w)
+	var written int64
+
+	write := func(format string, args ...interface{}) error {
+		n, err := fmt.Fprintf(buf, format, args...)
+		written += int64(n)
+		return err
+	}
+
+	err := write("%s %d %d\n", countsHeader, countsVersion, c.size)
+	if err != nil {
+		return written, err
+	}
+
+	contexts := make([]string, 0, len(c.ngrams))
+	for context := range c.ngrams {
+		contexts = append(contexts, context)
+	}
+	sort.Strings(contexts)
+
+	for _, context := range contexts {
+		nexts := c.ngrams[context]
+
+		keys := make([]string, 0, len(nexts))
+		for next := range nexts {
+			keys = append(keys, next)
+		}
+		sort.Strings(keys)
+
+		for _, next := range keys {
+			err := write("%d\t%s\t%s\n", nexts[next], next, context)
+			if err != nil {
+				return written, err
+			}
+		}
+	}
+
+	return written, buf.Flush()
+}
+
+// ReadCounts reads counts written by Counts.WriteTo. Counts written before
+// tags were escaped are converted.
+func ReadCounts(r io.Reader) (*Counts, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<24)
+
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("invalid counts: missing header")
+	}
+
+	c := NewCounts()
+
+	var version int
+	_, err := fmt.Sscanf(scanner.Text(), countsHeader+" %d %d", &version, &c.size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid counts header: %v", err)
+	}
+
+	if version < 1 || version > countsVersion {
+		return nil, fmt.Errorf("unsupported counts version %d", version)
+	}
+
+	line := 1
+	for scanner.Scan() {
+		line++
+
+		split := strings.SplitN(scanner.Text(), "\t", 3)
+		if len(split) != 3 {
+			return nil, fmt.Errorf("counts line %d: want 3 fields, got %d", line, len(split))
+		}
+
+		n, err := strconv.Atoi(split[0])
+		if err != nil {
+			return nil, fmt.Errorf("counts line %d: %v", line, err)
+		}
+
+		if version == 1 {
+			split[1] = migrateLegacy(split[1])
+			split[2] = migrateLegacy(split[2])
+		}
+
+		c.addOne(split[2], split[1], n)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// joinRaw returns the raw form of the tags joined with spaces, as they're
+// stored in the chain.
+func joinRaw(tags []Tag) string {
+	raw := make([]string, len(tags))
+	for i, tag := range tags {
+		raw[i] = tag.String()
+	}
+	return strings.Join(raw, " ")
+}
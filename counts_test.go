@@ -0,0 +1,77 @@
+package randtxt
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCountsWriteTo(t *testing.T) {
+	_, counts := countedChain(t, 3)
+
+	if counts.Size() != 3 {
+		t.Errorf("got size %d, want 3", counts.Size())
+	}
+
+	context := []Tag{{Text: "the", POS: "DT"}}
+	if counts.Count(context, Tag{Text: "poet", POS: "NN"}) == 0 {
+		t.Errorf("\"the poet\" wasn't counted")
+	}
+
+	buf := &bytes.Buffer{}
+	_, err := counts.WriteTo(buf)
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	read, err := ReadCounts(buf)
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	if read.Size() != counts.Size() {
+		t.Errorf("got size %d, want %d", read.Size(), counts.Size())
+	}
+
+	if !reflect.DeepEqual(read.ngrams, counts.ngrams) {
+		t.Errorf("read counts don't match the written counts")
+	}
+}
+
+func TestReadLegacyCounts(t *testing.T) {
+	legacy := "randtxt-counts 1 2\n" +
+		"3\tand/or/CC\t\n" +
+		"2\t50%/NN\tand/or/CC\n" +
+		"1\t</s>\t<s> and/or/CC\n"
+
+	counts, err := ReadCounts(strings.NewReader(legacy))
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	andOr := Tag{Text: "and/or", POS: "CC"}
+	percent := Tag{Text: "50%", POS: "NN"}
+
+	cases := []struct {
+		context  []Tag
+		next     Tag
+		expected int
+	}{
+		{nil, andOr, 3},
+		{[]Tag{andOr}, percent, 2},
+		{[]Tag{SentenceStartTag, andOr}, SentenceEndTag, 1},
+	}
+
+	for i, c := range cases {
+		actual := counts.Count(c.context, c.next)
+		if actual != c.expected {
+			t.Errorf("%d: got count %d, want %d", i, actual, c.expected)
+		}
+	}
+
+	_, err = ReadCounts(strings.NewReader("randtxt-counts 3 2\n"))
+	if err == nil {
+		t.Errorf("got nil error for an unsupported version")
+	}
+}
@@ -2,37 +2,123 @@ package randtxt
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"math/rand"
 	"strings"
+	"unicode"
 
 	"github.com/pboyd/markov"
 )
 
 // Generator generates random text from a model built by ModelBuilder.
+//
+// A Generator is not safe for concurrent use.
 type Generator struct {
 	chain markov.Chain
-
-	// TagSet is the language and tagset specific rules. This should match
-	// the TagSet used when the model was built.
+	rand  *rand.Rand
+	size  int
+	seeds []int
+
+	// markers is set if the chain has sentence markers.
+	markers bool
+
+	// metadata is nil if the chain doesn't have any.
+	metadata *Metadata
+
+	// start is the phrase the next stream starts with. See StartWith.
+	start *phraseMatch
+
+	// TagSet is the language and tagset specific rules. It must match
+	// the TagSet used when the model was built. If the chain has metadata
+	// this is checked before generating text, which fails with a
+	// TagSetMismatchError if they don't match.
+	//
+	// NewGenerator sets it to the registered TagSet named in the
+	// metadata, or PennTreebankTagSet. See RegisterTagSet.
 	TagSet TagSet
+
+	// Sampling controls how the next word is chosen.
+	Sampling Sampling
+
+	// Steering biases the text toward keywords.
+	Steering Steering
+
+	// MinWords and MaxWords limit the number of words in each sentence.
+	// Punctuation isn't counted. Zero means no limit.
+	MinWords, MaxWords int
+
+	// Index is used to look up words in the chain. It's optional, but
+	// makes StartWith faster on large chains. See BuildIndex.
+	Index *Index
+
+	// Ellipsis is added to the end of text that had to be cut off in the
+	// middle of a sentence by WriteWords or WriteChars. The zero tag
+	// means no ellipsis. For the Penn Treebank tagset use:
+	//
+	//	Tag{Text: "...", POS: ":"}
+	//
+	// For the universal tagset use:
+	//
+	//	Tag{Text: "...", POS: "PUNCT"}
+	Ellipsis Tag
 }
 
+// ErrWordLimits is returned when no sentence could be found within the
+// Generator's MinWords and MaxWords limits.
+var ErrWordLimits = errors.New("no sentence found within the word limits")
+
+// sentenceAttempts is the number of sentences that can be rejected by the
+// word limits before giving up.
+const sentenceAttempts = 1000
+
 // NewGenerator returns a new generator. Returns an error if the chain has an
-// unrecognized format.
-func NewGenerator(chain markov.Chain) (*Generator, error) {
-	_, err := inspectChain(chain)
+// unrecognized format, or if its metadata doesn't match it.
+//
+// "r" is the source of randomness for the generator. Two generators with
+// identically seeded sources will produce the same text. If "r" is nil a
+// source seeded with the current time is used.
+func NewGenerator(chain markov.Chain, r *rand.Rand) (*Generator, error) {
+	size, err := inspectChain(chain)
+	if err != nil {
+		return nil, err
+	}
+
+	markers, err := hasSentenceMarkers(chain)
 	if err != nil {
 		return nil, err
 	}
 
+	md, err := ReadMetadata(chain)
+	if err == ErrNoMetadata {
+		md, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if md != nil && md.NgramSize != size {
+		return nil, fmt.Errorf("chain metadata has ngram size %d, but the ngrams have %d tags", md.NgramSize, size)
+	}
+
 	return &Generator{
-		chain:  chain,
-		TagSet: PennTreebankTagSet,
+		chain:    chain,
+		rand:     newRand(r),
+		size:     size,
+		markers:  markers,
+		metadata: md,
+		TagSet:   chainTagSet(md),
 	}, nil
 }
 
+// Metadata returns the chain's metadata. Returns nil if the chain doesn't
+// have any.
+func (g *Generator) Metadata() *Metadata {
+	return g.metadata
+}
+
 func inspectChain(chain markov.Chain) (int, error) {
 	root, err := chain.Get(0)
 	if err != nil {
@@ -68,53 +154,267 @@ func (g *Generator) Paragraph(min, max int) (string, error) {
 
 // WriteParagraph writes a paragraph of random text to "out". The paragraph
 // will contain between "min" and "max" sentences.
+//
+// If the model was built with sentence markers and a paragraph break is
+// reached after "min" sentences, the paragraph ends early.
 func (g *Generator) WriteParagraph(out io.Writer, min, max int) error {
-	total := rand.Intn(max-min) + min
-	generated := 0
+	return g.WriteParagraphContext(context.Background(), out, min, max)
+}
+
+// WriteParagraphContext is like WriteParagraph but stops and returns the
+// context's error if the context is cancelled first.
+func (g *Generator) WriteParagraphContext(ctx context.Context, out io.Writer, min, max int) error {
+	total := g.rand.Intn(max-min) + min
+
+	sentences := g.sentences(ctx)
+	defer sentences.Close()
 
-	done := make(chan struct{})
-	defer close(done)
+	var last Tag
 
-	gen := g.generate(done)
+	for generated := 0; generated < total; generated++ {
+		sentence, afterBreak, err := sentences.Next()
+		if err != nil {
+			return err
+		}
 
-	for te := range gen {
-		if te.Tag.POS == "." {
+		if afterBreak && generated >= min {
 			break
 		}
+
+		last = g.writeTags(out, sentence, last)
 	}
 
-	first := <-gen
-	if first.Err != nil {
-		return first.Err
+	return nil
+}
+
+// Sentence returns a single sentence.
+func (g *Generator) Sentence() (string, error) {
+	text := &bytes.Buffer{}
+	err := g.WriteSentence(text)
+	if err != nil {
+		return "", err
 	}
-	io.WriteString(out, g.TagSet.Join(first.Tag, Tag{}))
 
-	last := first.Tag
+	return text.String(), nil
+}
+
+// Sentences returns "n" consecutive sentences.
+func (g *Generator) Sentences(n int) ([]string, error) {
+	sentences := g.sentences(context.Background())
+	defer sentences.Close()
 
-	for te := range gen {
-		if te.Err != nil {
-			return te.Err
+	text := make([]string, n)
+	for i := range text {
+		sentence, _, err := sentences.Next()
+		if err != nil {
+			return nil, err
 		}
 
-		tag := te.Tag
+		text[i] = g.joinTags(sentence)
+	}
+
+	return text, nil
+}
+
+// WriteSentence writes a single sentence of random text to "out".
+func (g *Generator) WriteSentence(out io.Writer) error {
+	return g.WriteSentenceContext(context.Background(), out)
+}
+
+// WriteSentenceContext is like WriteSentence but stops and returns the
+// context's error if the context is cancelled first.
+func (g *Generator) WriteSentenceContext(ctx context.Context, out io.Writer) error {
+	sentences := g.sentences(ctx)
+	defer sentences.Close()
 
+	sentence, _, err := sentences.Next()
+	if err != nil {
+		return err
+	}
+
+	g.writeTags(out, sentence, Tag{})
+	return nil
+}
+
+// writeTags joins the tags with the generator's TagSet and writes them to
+// "out". "last" is the tag that was written before them. Returns the last tag
+// that was written.
+func (g *Generator) writeTags(out io.Writer, tags []Tag, last Tag) Tag {
+	for _, tag := range tags {
 		io.WriteString(out, g.TagSet.Join(tag, last))
+		last = tag
+	}
+
+	return last
+}
+
+// sentences starts a new tag stream and returns a reader for the sentences
+// in it.
+func (g *Generator) sentences(ctx context.Context) *sentenceReader {
+	stream := g.generate(ctx)
+	return &sentenceReader{
+		g:      g,
+		stream: stream,
+
+		// A stream that starts with a phrase doesn't need to skip to
+		// the start of a sentence.
+		started: stream.phrase,
+	}
+}
+
+// sentenceReader splits a tag stream into sentences.
+type sentenceReader struct {
+	g       *Generator
+	stream  *tagStream
+	started bool
+}
+
+// Next returns the tags in the next sentence that fits within the word
+// limits. Boundary tags are not included. "afterBreak" is true if a paragraph
+// break came before the sentence.
+func (r *sentenceReader) Next() (sentence []Tag, afterBreak bool, err error) {
+	if !r.started {
+		r.started = true
+
+		if !r.g.markers {
+			// Without markers the stream may start mid-sentence.
+			// Skip ahead to the start of the next sentence.
+			for {
+				tag, err := r.stream.Next()
+				if err != nil {
+					return nil, false, err
+				}
+
+				if EndsSentence(r.g.TagSet, tag) {
+					break
+				}
+			}
+		}
+	}
+
+	for i := 0; i < sentenceAttempts; i++ {
+		sentence = nil
+
+		for {
+			tag, err := r.stream.Next()
+			if err != nil {
+				return nil, false, err
+			}
+
+			if tag == ParagraphBreakTag {
+				afterBreak = true
+			}
+
+			if !tag.IsBoundary() {
+				sentence = append(sentence, tag)
+			}
 
-		if tag.POS == "." {
-			generated++
-			if generated == total {
+			if r.g.endsSentence(tag) {
 				break
 			}
 		}
 
-		last = tag
+		if len(sentence) > 0 && r.g.fitsWordLimits(sentence) {
+			return sentence, afterBreak, nil
+		}
 	}
 
-	return nil
+	return nil, false, ErrWordLimits
+}
+
+// Close stops the underlying tag stream.
+func (r *sentenceReader) Close() {
+	r.stream.Close()
+}
+
+// fitsWordLimits tests if the sentence is within MinWords and MaxWords.
+func (g *Generator) fitsWordLimits(sentence []Tag) bool {
+	words := countWords(sentence)
+
+	if g.MinWords > 0 && words < g.MinWords {
+		return false
+	}
+
+	if g.MaxWords > 0 && words > g.MaxWords {
+		return false
+	}
+
+	return true
+}
+
+// countWords returns the number of tags that contain a letter or digit.
+func countWords(tags []Tag) int {
+	words := 0
+	for _, tag := range tags {
+		if strings.IndexFunc(tag.Text, isWordRune) >= 0 {
+			words++
+		}
+	}
+	return words
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// endsSentence tests if "tag" is the end of a sentence in the generator's
+// model.
+func (g *Generator) endsSentence(tag Tag) bool {
+	if g.markers {
+		return tag == SentenceEndTag
+	}
+
+	return EndsSentence(g.TagSet, tag)
+}
+
+func (g *Generator) randomSeed(ctx context.Context) (string, error) {
+	if g.seeds == nil {
+		var err error
+		g.seeds, err = seedIDs(ctx, g.chain, g.size)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return randomSeed(g.chain, g.seeds, g.rand)
+}
+
+// seedTags returns the tags from a seed that should be sent to the stream.
+// Seeds in a chain with sentence markers end with the start of a sentence, so
+// the tags before it belong to the previous sentence.
+func (g *Generator) seedTags(seed string) []string {
+	tags := strings.Split(seed, " ")
+	if !g.markers {
+		return tags
+	}
+
+	start := SentenceStartTag.String()
+	for i := len(tags) - 1; i >= 0; i-- {
+		if tags[i] == start {
+			return tags[i:]
+		}
+	}
+
+	return tags
 }
 
-func (g *Generator) generate(done chan struct{}) <-chan tagOrError {
+// generate starts a goroutine which sends an endless stream of tags. The
+// stream ends when it's closed or the context is cancelled.
+//
+// The goroutine uses the generator's random source, so nothing else may use
+// it until the stream has been closed.
+func (g *Generator) generate(ctx context.Context) *tagStream {
 	out := make(chan tagOrError)
+	stream := &tagStream{
+		ctx:    ctx,
+		tags:   out,
+		phrase: g.start != nil,
+		done:   make(chan struct{}),
+		exited: make(chan struct{}),
+	}
+
+	start := g.start
+	g.start = nil
 
 	send := func(tag Tag, err error) bool {
 		te := tagOrError{
@@ -125,34 +425,59 @@ func (g *Generator) generate(done chan struct{}) <-chan tagOrError {
 		select {
 		case out <- te:
 			return false
-		case <-done:
+		case <-stream.done:
+			return true
+		case <-ctx.Done():
 			return true
 		}
 	}
 
 	go func() {
+		defer close(stream.exited)
 		defer close(out)
 
-		past, err := randomSeed(g.chain)
+		err := checkTagSet(g.metadata, g.TagSet)
 		if err != nil {
 			send(Tag{}, err)
 			return
 		}
 
-		for _, rawTag := range strings.Split(past, " ") {
-			if send(parseTag(rawTag), nil) {
+		var past string
+		var seedTags []Tag
+
+		if start != nil {
+			past = start.seed
+			seedTags = start.tags
+		} else {
+			var err error
+			past, err = g.randomSeed(ctx)
+			if err != nil {
+				send(Tag{}, err)
+				return
+			}
+
+			for _, rawTag := range g.seedTags(past) {
+				seedTags = append(seedTags, parseTag(rawTag))
+			}
+		}
+
+		for _, tag := range seedTags {
+			if send(tag, nil) {
 				return
 			}
 		}
 
-		model, err := NewModel(g.chain, past)
+		model, err := NewModel(g.chain, past, g.rand)
 		if err != nil {
 			send(Tag{}, err)
 			return
 		}
+		model.seeds = g.seeds
+		model.Sampling = g.Sampling
+		model.Steering = g.Steering
 
 		for {
-			err := model.Step()
+			err := model.StepContext(ctx)
 			if err != nil {
 				send(Tag{}, err)
 				return
@@ -164,7 +489,39 @@ func (g *Generator) generate(done chan struct{}) <-chan tagOrError {
 		}
 	}()
 
-	return out
+	return stream
+}
+
+// tagStream is a stream of tags from Generator.generate.
+type tagStream struct {
+	ctx  context.Context
+	tags <-chan tagOrError
+
+	// phrase is set if the stream starts with a phrase from StartWith.
+	phrase bool
+
+	done   chan struct{}
+	exited chan struct{}
+}
+
+// Next returns the next tag in the stream, or the context's error if it's
+// cancelled first.
+func (s *tagStream) Next() (Tag, error) {
+	select {
+	case te, ok := <-s.tags:
+		if !ok {
+			return Tag{}, s.ctx.Err()
+		}
+		return te.Tag, te.Err
+	case <-s.ctx.Done():
+		return Tag{}, s.ctx.Err()
+	}
+}
+
+// Close stops the stream and waits for the goroutine to exit.
+func (s *tagStream) Close() {
+	close(s.done)
+	<-s.exited
 }
 
 type tagOrError struct {
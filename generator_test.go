@@ -1,9 +1,15 @@
 package randtxt
 
 import (
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
 	"os"
 	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/pboyd/markov"
 )
@@ -17,7 +23,7 @@ func TestParagraph(t *testing.T) {
 		max = 5
 	)
 
-	g, err := NewGenerator(chain)
+	g, err := NewGenerator(chain, nil)
 	if err != nil {
 		t.Fatalf("invalid chain: %v", err)
 	}
@@ -27,6 +33,45 @@ func TestParagraph(t *testing.T) {
 		t.Fatalf("got error %v, want nil", err)
 	}
 
+	checkParagraph(t, text, min, max)
+}
+
+func TestSentenceMarkersParagraph(t *testing.T) {
+	chain := markov.NewMemoryChain(0)
+	b := NewModelBuilder(chain, 3)
+	b.SentenceMarkers = true
+
+	err := b.Feed(sliceFeed(readTestFile(t, "testfiles/ion/tagged.tsv", ReadTSV)...))
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+
+	g, err := NewGenerator(chain, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("invalid chain: %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		text, err := g.Paragraph(2, 4)
+		if err != nil {
+			t.Fatalf("got error %v, want nil", err)
+		}
+
+		checkParagraph(t, text, 2, 4)
+
+		if strings.Contains(text, "<") {
+			t.Errorf("text contained a boundary tag: %q", text)
+		}
+
+		if !regexp.MustCompile(`^[A-Z]`).MatchString(text) {
+			t.Errorf("text did not start with a capital letter: %q", text)
+		}
+	}
+}
+
+func checkParagraph(t *testing.T, text string, min, max int) {
+	t.Helper()
+
 	matchTag := regexp.MustCompile(`/[A-Z$.:]+`)
 	if matchTag.MatchString(text) {
 		t.Errorf("text contained POS tags")
@@ -72,11 +117,30 @@ func testChain(t *testing.T, path string) (chain markov.Chain, close func() erro
 	return chain, fh.Close
 }
 
+// readTestFile reads every tag from the file at "path" with "read", such as
+// ReadTSV.
+func readTestFile(t *testing.T, path string, read func(io.Reader) ([]Tag, error)) []Tag {
+	t.Helper()
+
+	fh, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("could not open %q: %v", path, err)
+	}
+	defer fh.Close()
+
+	tags, err := read(fh)
+	if err != nil {
+		t.Fatalf("could not read %q: %v", path, err)
+	}
+
+	return tags
+}
+
 func TestValidChain(t *testing.T) {
 	chain, close := testChain(t, "testfiles/ion/trigram.mkv")
 	defer close()
 
-	_, err := NewGenerator(chain)
+	_, err := NewGenerator(chain, nil)
 	if err != nil {
 		t.Errorf("got error for valid chain: %v", err)
 	}
@@ -91,7 +155,7 @@ func TestInvalidChains(t *testing.T) {
 	}
 
 	for desc, chain := range cases {
-		_, err := NewGenerator(chain)
+		_, err := NewGenerator(chain, nil)
 		if err == nil {
 			t.Errorf("%s: no error", desc)
 		}
@@ -103,3 +167,159 @@ func invalidChain(root interface{}) markov.Chain {
 	chain.Add(root)
 	return chain
 }
+
+func TestGoldenParagraph(t *testing.T) {
+	chain, close := testChain(t, "testfiles/ion/trigram.mkv")
+	defer close()
+
+	cases := map[int64]string{
+		1: "At any rate he will know what a slave ought to say? And if you have art, then, what I am saying, receive the power of the original magnet from one another? The rhapsode like yourself and the actor are intermediate links, and the generations of gods and heroes?",
+		7: "This stone not only attracts iron rings, but also imparts to them a similar power of attracting other rings; and sometimes you may see a number of pieces of iron and rings suspended from one another? The rhapsode like yourself and the actor are intermediate links, and the mind is no longer in him: When he has not attained to this state, he is powerless and is unable to utter his oracles.",
+	}
+
+	for seed, expected := range cases {
+		g, err := NewGenerator(chain, rand.New(rand.NewSource(seed)))
+		if err != nil {
+			t.Fatalf("invalid chain: %v", err)
+		}
+
+		actual, err := g.Paragraph(2, 4)
+		if err != nil {
+			t.Fatalf("got error %v, want nil", err)
+		}
+
+		if actual != expected {
+			t.Errorf("seed %d: got %q, want %q", seed, actual, expected)
+		}
+	}
+}
+
+func TestIndependentGenerators(t *testing.T) {
+	chain, close := testChain(t, "testfiles/ion/trigram.mkv")
+	defer close()
+
+	newGen := func(seed int64) *Generator {
+		g, err := NewGenerator(chain, rand.New(rand.NewSource(seed)))
+		if err != nil {
+			t.Fatalf("invalid chain: %v", err)
+		}
+		return g
+	}
+
+	paragraphs := func(gens ...*Generator) []string {
+		text := make([]string, 0, len(gens)*3)
+		for i := 0; i < 3; i++ {
+			for _, g := range gens {
+				p, err := g.Paragraph(2, 4)
+				if err != nil {
+					t.Fatalf("got error %v, want nil", err)
+				}
+				text = append(text, p)
+			}
+		}
+		return text
+	}
+
+	alone := paragraphs(newGen(1))
+	interleaved := paragraphs(newGen(1), newGen(2))
+
+	for i := range alone {
+		if alone[i] != interleaved[i*2] {
+			t.Errorf("paragraph %d: got %q, want %q", i, interleaved[i*2], alone[i])
+		}
+	}
+}
+
+func TestSentence(t *testing.T) {
+	chain, close := testChain(t, "testfiles/ion/trigram.mkv")
+	defer close()
+
+	g, err := NewGenerator(chain, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("invalid chain: %v", err)
+	}
+
+	text, err := g.Sentence()
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	checkParagraph(t, text, 1, 1)
+}
+
+func TestSentences(t *testing.T) {
+	chain, close := testChain(t, "testfiles/ion/trigram.mkv")
+	defer close()
+
+	g, err := NewGenerator(chain, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("invalid chain: %v", err)
+	}
+	g.MinWords = 5
+	g.MaxWords = 10
+
+	sentences, err := g.Sentences(5)
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	if len(sentences) != 5 {
+		t.Fatalf("got %d sentences, want 5", len(sentences))
+	}
+
+	matchWord := regexp.MustCompile(`[\pL\pN]+(['-][\pL\pN]+)*`)
+
+	for _, text := range sentences {
+		checkParagraph(t, text, 1, 1)
+
+		words := len(matchWord.FindAllString(text, -1))
+		if words < g.MinWords || words > g.MaxWords {
+			t.Errorf("got %d words in %q, want between %d and %d", words, text, g.MinWords, g.MaxWords)
+		}
+	}
+}
+
+func TestWordLimitsError(t *testing.T) {
+	chain, close := testChain(t, "testfiles/ion/trigram.mkv")
+	defer close()
+
+	g, err := NewGenerator(chain, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("invalid chain: %v", err)
+	}
+	g.MinWords = 10000
+
+	_, err = g.Sentence()
+	if err != ErrWordLimits {
+		t.Errorf("got error %v, want %v", err, ErrWordLimits)
+	}
+}
+
+func TestWriteParagraphContext(t *testing.T) {
+	// This chain never ends a sentence, so the paragraph never ends.
+	chain := markov.NewMemoryChain(0)
+	b := NewModelBuilder(chain, 2)
+	err := b.Feed(sliceFeed(
+		Tag{Text: "Paul", POS: "NNP"},
+		Tag{Text: "George", POS: "NNP"},
+		Tag{Text: "Ringo", POS: "NNP"},
+		Tag{Text: "Paul", POS: "NNP"},
+		Tag{Text: "George", POS: "NNP"},
+	))
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+
+	g, err := NewGenerator(chain, nil)
+	if err != nil {
+		t.Fatalf("invalid chain: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err = g.WriteParagraphContext(ctx, ioutil.Discard, 1, 2)
+	if err != context.DeadlineExceeded {
+		t.Errorf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -1,3 +1,5 @@
 module github.com/pboyd/randtxt
 
+go 1.18
+
 require github.com/pboyd/markov v1.0.1
@@ -0,0 +1,360 @@
+package randtxt
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pboyd/markov"
+)
+
+const indexHeader = "randtxt-index 2"
+
+var errStaleIndex = errors.New("index is out of date with the chain")
+
+// Index maps words and tags to the IDs of the ngrams in a chain that contain
+// them.
+//
+// IDs are only meaningful for the chain the index was built from. An index
+// for a chain on disk can be saved next to it with WriteTo and loaded with
+// ReadIndex.
+type Index struct {
+	size   int
+	root   string
+	tags   map[Tag][]int
+	words  map[string][]int
+	ngrams int
+
+	// values and last are the number of values in the chain and the ID of
+	// the last one, so Verify can tell if values were added since.
+	values int
+	last   int
+}
+
+// BuildIndex indexes every ngram in the chain.
+func BuildIndex(chain markov.Chain) (*Index, error) {
+	return BuildIndexContext(context.Background(), chain)
+}
+
+// BuildIndexContext is like BuildIndex but stops and returns the context's
+// error if the context is cancelled first.
+func BuildIndexContext(ctx context.Context, chain markov.Chain) (*Index, error) {
+	size, err := inspectChain(chain)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := chain.Get(0)
+	if err != nil {
+		return nil, err
+	}
+
+	ix := newIndex(size, root.(string))
+
+	err = walkValues(ctx, chain, func(id int, value string) error {
+		ix.values++
+		ix.last = id
+
+		if isMetadata(value) {
+			return nil
+		}
+
+		grams := strings.Split(value, " ")
+		if len(grams) != size {
+			return nil
+		}
+
+		tags := make([]Tag, len(grams))
+		for i, gram := range grams {
+			tags[i] = parseTag(gram)
+		}
+
+		ix.add(id, tags)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Chains that can't be iterated efficiently aren't walked in ID order.
+	for _, ids := range ix.tags {
+		sort.Ints(ids)
+	}
+	for _, ids := range ix.words {
+		sort.Ints(ids)
+	}
+
+	return ix, nil
+}
+
+func newIndex(size int, root string) *Index {
+	return &Index{
+		size:  size,
+		root:  root,
+		tags:  map[Tag][]int{},
+		words: map[string][]int{},
+	}
+}
+
+// add indexes the ngram with the given ID.
+func (ix *Index) add(id int, tags []Tag) {
+	ix.ngrams++
+
+	for i, tag := range tags {
+		if !containsTag(tags[:i], tag) {
+			ix.tags[tag] = append(ix.tags[tag], id)
+		}
+
+		word := strings.ToLower(tag.Text)
+		if !containsWord(tags[:i], word) {
+			ix.words[word] = append(ix.words[word], id)
+		}
+	}
+}
+
+func containsTag(tags []Tag, tag Tag) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func containsWord(tags []Tag, word string) bool {
+	for _, t := range tags {
+		if strings.ToLower(t.Text) == word {
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the number of ngrams in the index.
+func (ix *Index) Len() int {
+	return ix.ngrams
+}
+
+// Tag returns the IDs of the ngrams that contain "tag", in increasing order.
+func (ix *Index) Tag(tag Tag) []int {
+	return ix.tags[tag]
+}
+
+// Word returns the IDs of the ngrams that contain a tag with the text "word",
+// regardless of case or part of speech, in increasing order.
+func (ix *Index) Word(word string) []int {
+	return ix.words[strings.ToLower(word)]
+}
+
+// Verify returns an error if the index wasn't built from "chain", or if
+// values were added to the chain after the index was built.
+func (ix *Index) Verify(chain markov.Chain) error {
+	root, err := chain.Get(0)
+	if err != nil {
+		return err
+	}
+
+	if root != ix.root {
+		return errors.New("index was built from a different chain")
+	}
+
+	// Values are only ever appended, so if the last value is still the
+	// last the chain hasn't grown.
+	if ic, ok := chain.(markov.IterativeChain); ok {
+		last, err := ic.Get(ix.last)
+		if err != nil || last == nil {
+			return errStaleIndex
+		}
+
+		_, err = ic.Next(ix.last)
+		if err != markov.ErrBrokenChain {
+			return errStaleIndex
+		}
+
+		return nil
+	}
+
+	values := 0
+	err = walkValues(context.Background(), chain, func(int, string) error {
+		values++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if values != ix.values {
+		return errStaleIndex
+	}
+
+	return nil
+}
+
+// WriteTo writes the index to "w" in a line-based text format.
+func (ix *Index) WriteTo(w io.Writer) (int64, error) {
+	buf := bufio.NewWriter(w)
+	var written int64
+
+	write := func(format string, args ...interface{}) error {
+		n, err := fmt.Fprintf(buf, format, args...)
+		written += int64(n)
+		return err
+	}
+
+	err := write("%s %d %d %d %d\n%s\n", indexHeader, ix.size, ix.ngrams, ix.values, ix.last, ix.root)
+	if err != nil {
+		return written, err
+	}
+
+	tags := make([]Tag, 0, len(ix.tags))
+	for tag := range ix.tags {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].String() < tags[j].String()
+	})
+
+	for _, tag := range tags {
+		ids := ix.tags[tag]
+		fields := make([]string, len(ids))
+		for i, id := range ids {
+			fields[i] = strconv.Itoa(id)
+		}
+
+		err := write("%s\t%s\n", tag, strings.Join(fields, " "))
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, buf.Flush()
+}
+
+// ReadIndex reads an index written by Index.WriteTo.
+func ReadIndex(r io.Reader) (*Index, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<24)
+
+	if !scanner.Scan() {
+		return nil, readIndexError(scanner, "missing header")
+	}
+
+	var size, ngrams, values, last int
+	_, err := fmt.Sscanf(scanner.Text(), indexHeader+" %d %d %d %d", &size, &ngrams, &values, &last)
+	if err != nil {
+		if strings.HasPrefix(scanner.Text(), "randtxt-index 1 ") {
+			return nil, errors.New("index is from an older version, rebuild it")
+		}
+		return nil, fmt.Errorf("invalid index header: %v", err)
+	}
+
+	if !scanner.Scan() {
+		return nil, readIndexError(scanner, "missing root")
+	}
+
+	ix := newIndex(size, scanner.Text())
+	ix.ngrams = ngrams
+	ix.values = values
+	ix.last = last
+
+	line := 2
+	for scanner.Scan() {
+		line++
+
+		split := strings.SplitN(scanner.Text(), "\t", 2)
+		if len(split) != 2 {
+			return nil, fmt.Errorf("index line %d: missing tab", line)
+		}
+
+		tag := parseTag(split[0])
+		if tag.IsZero() {
+			return nil, fmt.Errorf("index line %d: unrecognized tag format %q", line, split[0])
+		}
+
+		fields := strings.Fields(split[1])
+		ids := make([]int, len(fields))
+		for i, field := range fields {
+			ids[i], err = strconv.Atoi(field)
+			if err != nil {
+				return nil, fmt.Errorf("index line %d: %v", line, err)
+			}
+		}
+
+		ix.tags[tag] = ids
+
+		word := strings.ToLower(tag.Text)
+		ix.words[word] = mergeIDs(ix.words[word], ids)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return ix, nil
+}
+
+func readIndexError(scanner *bufio.Scanner, msg string) error {
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return errors.New("invalid index: " + msg)
+}
+
+// mergeIDs merges two sorted lists of IDs, removing duplicates.
+func mergeIDs(a, b []int) []int {
+	merged := make([]int, 0, len(a)+len(b))
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		var next int
+		switch {
+		case j >= len(b) || (i < len(a) && a[i] < b[j]):
+			next = a[i]
+			i++
+		case i >= len(a) || b[j] < a[i]:
+			next = b[j]
+			j++
+		default:
+			next = a[i]
+			i++
+			j++
+		}
+
+		if len(merged) == 0 || merged[len(merged)-1] != next {
+			merged = append(merged, next)
+		}
+	}
+
+	return merged
+}
+
+// eachIndexed calls fn with the value of each ID.
+func eachIndexed(ctx context.Context, chain markov.Chain, ids []int, fn func(id int, value string) error) error {
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		raw, err := chain.Get(id)
+		if err != nil {
+			return err
+		}
+
+		value, ok := raw.(string)
+		if !ok {
+			continue
+		}
+
+		err = fn(id, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package randtxt
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pboyd/markov"
+)
+
+func TestIndex(t *testing.T) {
+	chain, close := testChain(t, "testfiles/ion/trigram.mkv")
+	defer close()
+
+	ix, err := BuildIndex(chain)
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+
+	ids := ix.Word("POET")
+	if len(ids) == 0 {
+		t.Fatalf("no ngrams found for %q", "poet")
+	}
+
+	for _, id := range ids {
+		raw, err := chain.Get(id)
+		if err != nil {
+			t.Fatalf("got error: %v", err)
+		}
+
+		if !strings.Contains(strings.ToLower(raw.(string)), "poet/") {
+			t.Errorf("%d: %q doesn't contain %q", id, raw, "poet")
+		}
+	}
+
+	tagIDs := ix.Tag(Tag{Text: "poet", POS: "NN"})
+	if len(tagIDs) == 0 || len(tagIDs) > len(ids) {
+		t.Errorf("got %d ngrams for the tag, want between 1 and %d", len(tagIDs), len(ids))
+	}
+
+	if len(ix.Word("purple")) != 0 {
+		t.Errorf("found ngrams for a word that's not in the chain")
+	}
+}
+
+func TestIndexReadWrite(t *testing.T) {
+	chain, close := testChain(t, "testfiles/ion/trigram.mkv")
+	defer close()
+
+	ix, err := BuildIndex(chain)
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+
+	buf := &bytes.Buffer{}
+	_, err = ix.WriteTo(buf)
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+
+	read, err := ReadIndex(buf)
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+
+	if !reflect.DeepEqual(ix, read) {
+		t.Errorf("index changed after writing and reading")
+	}
+
+	err = read.Verify(chain)
+	if err != nil {
+		t.Errorf("got error: %v", err)
+	}
+
+	other, close := testChain(t, "testfiles/ion/unigram.mkv")
+	defer close()
+
+	err = read.Verify(other)
+	if err == nil {
+		t.Errorf("index verified against the wrong chain")
+	}
+}
+
+func TestVerifyStaleIndex(t *testing.T) {
+	chain := markov.NewMemoryChain(0)
+	b := NewModelBuilder(chain, 2)
+	err := b.Feed(sliceFeed(readTestFile(t, "testfiles/ion/tagged.tsv", ReadTSV)...))
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	ix, err := BuildIndex(chain)
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+
+	err = ix.Verify(chain)
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+
+	_, err = chain.Add("purple/JJ prose/NN")
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+
+	err = ix.Verify(chain)
+	if err == nil {
+		t.Errorf("index verified after the chain grew")
+	}
+}
+
+func TestStartWithIndex(t *testing.T) {
+	chain, close := testChain(t, "testfiles/ion/trigram.mkv")
+	defer close()
+
+	g, err := NewGenerator(chain, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("invalid chain: %v", err)
+	}
+
+	g.Index, err = BuildIndex(chain)
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+
+	err = g.StartWith("the poet is a light")
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+
+	text, err := g.Paragraph(1, 3)
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	if !strings.HasPrefix(text, "The poet is a light") {
+		t.Errorf("got %q", text)
+	}
+}
@@ -0,0 +1,65 @@
+package randtxt
+
+import (
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// InlineReader reads tags that are written inline, as whitespace separated
+// tokens of a word, a separator and a POS. The Stanford POS Tagger's default
+// output uses "_" ("Welcome_UH ,_, Ion_NNP"), and NLTK uses "/"
+// ("Welcome/UH ,/, Ion/NNP").
+//
+// The POS is split from the last separator in each token, so words that
+// contain the separator themselves, like "and/or/CC", are read correctly.
+// A token without a separator, or with a blank word or POS, is an error.
+type InlineReader struct {
+	lines     *lineScanner
+	separator rune
+
+	// tokens are the unread tokens of the current line.
+	tokens []string
+
+	// Name is the file name used in errors. It's optional.
+	Name string
+}
+
+// NewInlineReader returns an InlineReader that reads from "r".
+func NewInlineReader(r io.Reader, separator rune) *InlineReader {
+	return &InlineReader{
+		lines:     newLineScanner(r),
+		separator: separator,
+	}
+}
+
+// ReadTag returns the next tag.
+func (ir *InlineReader) ReadTag() (Tag, error) {
+	for len(ir.tokens) == 0 {
+		line, err := ir.lines.next(ir.Name)
+		if err != nil {
+			return Tag{}, err
+		}
+
+		ir.tokens = strings.Fields(line)
+	}
+
+	token := ir.tokens[0]
+	ir.tokens = ir.tokens[1:]
+
+	i := strings.LastIndex(token, string(ir.separator))
+	end := i + utf8.RuneLen(ir.separator)
+	if i <= 0 || end == len(token) {
+		return Tag{}, ir.lines.errorf(ir.Name, "token %q isn't in the form word%cPOS", token, ir.separator)
+	}
+
+	return Tag{
+		Text: token[:i],
+		POS:  token[end:],
+	}, nil
+}
+
+// ReadInline reads every tag with an InlineReader.
+func ReadInline(r io.Reader, separator rune) ([]Tag, error) {
+	return readAllTags(NewInlineReader(r, separator))
+}
@@ -0,0 +1,93 @@
+package randtxt
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadInline(t *testing.T) {
+	cases := []struct {
+		text      string
+		separator rune
+		expected  []Tag
+	}{
+		{
+			text:      "Welcome_UH ,_, Ion_NNP ._.\nAre_VBP you_PRP\n\nthere_RB ?_.",
+			separator: '_',
+			expected: []Tag{
+				{Text: "Welcome", POS: "UH"},
+				{Text: ",", POS: ","},
+				{Text: "Ion", POS: "NNP"},
+				{Text: ".", POS: "."},
+				{Text: "Are", POS: "VBP"},
+				{Text: "you", POS: "PRP"},
+				{Text: "there", POS: "RB"},
+				{Text: "?", POS: "."},
+			},
+		},
+		{
+			text:      "snake_case_NN __SYM _/_NN",
+			separator: '_',
+			expected: []Tag{
+				{Text: "snake_case", POS: "NN"},
+				{Text: "_", POS: "SYM"},
+				{Text: "_/", POS: "NN"},
+			},
+		},
+		{
+			text:      "  Welcome/UH ,/, and/or/CC //SYM 1/2/CD  ",
+			separator: '/',
+			expected: []Tag{
+				{Text: "Welcome", POS: "UH"},
+				{Text: ",", POS: ","},
+				{Text: "and/or", POS: "CC"},
+				{Text: "/", POS: "SYM"},
+				{Text: "1/2", POS: "CD"},
+			},
+		},
+	}
+
+	for i, c := range cases {
+		tags, err := ReadInline(strings.NewReader(c.text), c.separator)
+		if err != nil {
+			t.Errorf("%d: got error %v, want nil", i, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(tags, c.expected) {
+			t.Errorf("%d: got %v, want %v", i, tags, c.expected)
+		}
+	}
+}
+
+func TestReadInlineErrors(t *testing.T) {
+	cases := []struct {
+		text      string
+		separator rune
+		err       string
+	}{
+		{
+			text:      "Welcome_UH\nIon",
+			separator: '_',
+			err:       `line 2: token "Ion" isn't in the form word_POS`,
+		},
+		{
+			text:      "Welcome/",
+			separator: '/',
+			err:       `line 1: token "Welcome/" isn't in the form word/POS`,
+		},
+		{
+			text:      "Welcome_UH /NN",
+			separator: '/',
+			err:       `line 1: token "Welcome_UH" isn't in the form word/POS`,
+		},
+	}
+
+	for i, c := range cases {
+		_, err := ReadInline(strings.NewReader(c.text), c.separator)
+		if err == nil || err.Error() != c.err {
+			t.Errorf("%d: got error %v, want %q", i, err, c.err)
+		}
+	}
+}
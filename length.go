@@ -0,0 +1,137 @@
+package randtxt
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"unicode/utf8"
+)
+
+// Words returns text with at most "n" words. See WriteWords.
+func (g *Generator) Words(n int) (string, error) {
+	text := &bytes.Buffer{}
+	err := g.WriteWords(text, n)
+	if err != nil {
+		return "", err
+	}
+
+	return text.String(), nil
+}
+
+// WriteWords writes text with at most "n" words to "out". Punctuation isn't
+// counted.
+//
+// The text is made of whole sentences, as many as will fit. If the first
+// sentence is too long it's truncated and followed by the generator's Ellipsis.
+func (g *Generator) WriteWords(out io.Writer, n int) error {
+	return g.WriteWordsContext(context.Background(), out, n)
+}
+
+// WriteWordsContext is like WriteWords but stops and returns the context's
+// error if the context is cancelled first.
+func (g *Generator) WriteWordsContext(ctx context.Context, out io.Writer, n int) error {
+	return g.writeBounded(ctx, out, func(tags []Tag) bool {
+		return countWords(tags) <= n
+	})
+}
+
+// Chars returns text with at most "n" characters. See WriteChars.
+func (g *Generator) Chars(n int) (string, error) {
+	text := &bytes.Buffer{}
+	err := g.WriteChars(text, n)
+	if err != nil {
+		return "", err
+	}
+
+	return text.String(), nil
+}
+
+// WriteChars writes text with at most "n" characters (runes) to "out".
+//
+// The text is made of whole sentences, as many as will fit. If the first
+// sentence is too long it's truncated and followed by the generator's Ellipsis.
+func (g *Generator) WriteChars(out io.Writer, n int) error {
+	return g.WriteCharsContext(context.Background(), out, n)
+}
+
+// WriteCharsContext is like WriteChars but stops and returns the context's
+// error if the context is cancelled first.
+func (g *Generator) WriteCharsContext(ctx context.Context, out io.Writer, n int) error {
+	return g.writeBounded(ctx, out, func(tags []Tag) bool {
+		return utf8.RuneCountInString(g.joinTags(tags)) <= n
+	})
+}
+
+// writeBounded writes as many whole sentences as "fits" allows. If not even
+// one sentence fits it's truncated instead. It stops early at a sentence
+// without any words.
+func (g *Generator) writeBounded(ctx context.Context, out io.Writer, fits func([]Tag) bool) error {
+	sentences := g.sentences(ctx)
+	defer sentences.Close()
+
+	var tags []Tag
+
+	for {
+		sentence, _, err := sentences.Next()
+		if err != nil {
+			return err
+		}
+
+		// A sentence without words always fits a word limit, so more of
+		// them would never end the text.
+		if countWords(sentence) == 0 {
+			break
+		}
+
+		next := make([]Tag, 0, len(tags)+len(sentence))
+		next = append(next, tags...)
+		next = append(next, sentence...)
+
+		if !fits(next) {
+			if len(tags) == 0 {
+				tags = g.truncate(sentence, fits)
+			}
+			break
+		}
+
+		tags = next
+	}
+
+	g.writeTags(out, tags, Tag{})
+	return nil
+}
+
+// truncate returns the longest start of the sentence, followed by the
+// Ellipsis, that fits. Trailing punctuation is removed before the ellipsis.
+// Returns nil if not even a single word fits.
+func (g *Generator) truncate(sentence []Tag, fits func([]Tag) bool) []Tag {
+	for end := len(sentence) - 1; end > 0; end-- {
+		tags := make([]Tag, end, end+1)
+		copy(tags, sentence[:end])
+
+		for len(tags) > 0 && countWords(tags[len(tags)-1:]) == 0 {
+			tags = tags[:len(tags)-1]
+		}
+
+		if len(tags) == 0 {
+			break
+		}
+
+		if !g.Ellipsis.IsZero() {
+			tags = append(tags, g.Ellipsis)
+		}
+
+		if fits(tags) {
+			return tags
+		}
+	}
+
+	return nil
+}
+
+// joinTags joins the tags into a string with the generator's TagSet.
+func (g *Generator) joinTags(tags []Tag) string {
+	text := &bytes.Buffer{}
+	g.writeTags(text, tags, Tag{})
+	return text.String()
+}
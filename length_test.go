@@ -0,0 +1,125 @@
+package randtxt
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pboyd/markov"
+)
+
+func TestChars(t *testing.T) {
+	chain, close := testChain(t, "testfiles/ion/trigram.mkv")
+	defer close()
+
+	g, err := NewGenerator(chain, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("invalid chain: %v", err)
+	}
+	g.Ellipsis = Tag{Text: "...", POS: ":"}
+
+	for _, limit := range []int{20, 50, 140, 280, 1000} {
+		for i := 0; i < 5; i++ {
+			text, err := g.Chars(limit)
+			if err != nil {
+				t.Fatalf("got error %v, want nil", err)
+			}
+
+			if text == "" {
+				t.Errorf("%d: got empty text", limit)
+			}
+
+			if utf8.RuneCountInString(text) > limit {
+				t.Errorf("%d: got %d characters in %q", limit, utf8.RuneCountInString(text), text)
+			}
+
+			if !strings.HasSuffix(text, "...") {
+				checkParagraph(t, text, 1, limit)
+			}
+		}
+	}
+}
+
+func TestWords(t *testing.T) {
+	chain, close := testChain(t, "testfiles/ion/trigram.mkv")
+	defer close()
+
+	g, err := NewGenerator(chain, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("invalid chain: %v", err)
+	}
+
+	for _, limit := range []int{3, 10, 50, 100} {
+		for i := 0; i < 5; i++ {
+			text, err := g.Words(limit)
+			if err != nil {
+				t.Fatalf("got error %v, want nil", err)
+			}
+
+			words := len(strings.Fields(text))
+			if words == 0 || words > limit {
+				t.Errorf("%d: got %d words in %q", limit, words, text)
+			}
+		}
+	}
+}
+
+func TestWordsWithoutWords(t *testing.T) {
+	// Every sentence in this chain is a lone period.
+	chain := markov.NewMemoryChain(0)
+	b := NewModelBuilder(chain, 2)
+	err := b.Feed(sliceFeed(
+		Tag{Text: ".", POS: "."},
+		Tag{Text: ".", POS: "."},
+		Tag{Text: ".", POS: "."},
+	))
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+
+	g, err := NewGenerator(chain, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("invalid chain: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	err = g.WriteWordsContext(ctx, &bytes.Buffer{}, 5)
+	if err != nil {
+		t.Errorf("got error %v, want nil", err)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	chain, close := testChain(t, "testfiles/ion/trigram.mkv")
+	defer close()
+
+	g, err := NewGenerator(chain, nil)
+	if err != nil {
+		t.Fatalf("invalid chain: %v", err)
+	}
+	g.Ellipsis = Tag{Text: "...", POS: ":"}
+
+	sentence := []Tag{
+		{Text: "one", POS: "CD"},
+		{Text: "two", POS: "CD"},
+		{Text: ",", POS: ","},
+		{Text: "three", POS: "CD"},
+		{Text: ".", POS: "."},
+	}
+
+	fits := func(tags []Tag) bool {
+		return utf8.RuneCountInString(g.joinTags(tags)) <= 12
+	}
+
+	actual := g.joinTags(g.truncate(sentence, fits))
+	expected := "One two..."
+	if actual != expected {
+		t.Errorf("got %q, want %q", actual, expected)
+	}
+}
@@ -0,0 +1,179 @@
+package randtxt
+
+import "strings"
+
+// TagMapper rewrites the POS, and sometimes the text, of tags to convert text
+// from one tagset to another. Use it to merge corpora that were tagged with
+// different tagsets into one model:
+//
+//	builder.TagSet = UniversalTagSet
+//	err := builder.Feed(PennToUniversal().MapAll(pennTags), universalTags)
+type TagMapper struct {
+	// POS maps each source POS to its replacement.
+	POS map[string]string
+
+	// Words maps a lower case word and its source POS, in "word/POS"
+	// form, to a replacement POS. It's checked before POS, for words
+	// that the target tagset treats differently.
+	Words map[string]string
+
+	// Unknown replaces any POS that isn't in POS. If it's blank, the POS
+	// is left as it is.
+	Unknown string
+
+	// Text maps words to replacement text, for words the source tagset
+	// spells differently, like the "-LRB-" Penn Treebank taggers write for
+	// "(". The POS is mapped from the original text.
+	Text map[string]string
+}
+
+// Map returns "tag" with its POS, and possibly its text, replaced. Boundary
+// tags are returned unchanged.
+func (m *TagMapper) Map(tag Tag) Tag {
+	if tag.IsBoundary() || tag.IsZero() {
+		return tag
+	}
+
+	if pos, ok := m.Words[strings.ToLower(tag.Text)+"/"+tag.POS]; ok {
+		tag.POS = pos
+	} else if pos, ok := m.POS[tag.POS]; ok {
+		tag.POS = pos
+	} else if m.Unknown != "" {
+		tag.POS = m.Unknown
+	}
+
+	if text, ok := m.Text[tag.Text]; ok {
+		tag.Text = text
+	}
+
+	return tag
+}
+
+// MapAll returns a channel with every tag from "tags" mapped, so it can be
+// passed to ModelBuilder.Feed. The returned channel is closed after "tags"
+// is.
+func (m *TagMapper) MapAll(tags <-chan Tag) <-chan Tag {
+	mapped := make(chan Tag)
+
+	go func() {
+		defer close(mapped)
+
+		for tag := range tags {
+			mapped <- m.Map(tag)
+		}
+	}()
+
+	return mapped
+}
+
+// PennToUniversal returns a TagMapper that converts Penn Treebank tags (see
+// PennTreebankTagSet) to Universal POS tags (see UniversalTagSet).
+//
+// The conversion is by POS alone, except for forms of "be" (AUX), "not"
+// (PART) and common subordinating conjunctions (SCONJ). Other auxiliary
+// verbs can't be told apart from main verbs by their POS, so they become
+// VERB. Bracket tokens, like "-LRB-", are replaced with the bracket itself.
+// "$" and "#" become SYM, which UniversalTagSet keeps.
+func PennToUniversal() *TagMapper {
+	m := &TagMapper{
+		POS: map[string]string{
+			"#":     "SYM",
+			"$":     "SYM",
+			"''":    "PUNCT",
+			"``":    "PUNCT",
+			",":     "PUNCT",
+			"-LRB-": "PUNCT",
+			"-RRB-": "PUNCT",
+			".":     "PUNCT",
+			":":     "PUNCT",
+			"CC":    "CCONJ",
+			"CD":    "NUM",
+			"DT":    "DET",
+			"EX":    "PRON",
+			"FW":    "X",
+			"IN":    "ADP",
+			"JJ":    "ADJ",
+			"JJR":   "ADJ",
+			"JJS":   "ADJ",
+			"LS":    "X",
+			"MD":    "AUX",
+			"NN":    "NOUN",
+			"NNS":   "NOUN",
+			"NNP":   "PROPN",
+			"NNPS":  "PROPN",
+			"PDT":   "DET",
+			"POS":   "PART",
+			"PRP":   "PRON",
+			"PRP$":  "PRON",
+			"RB":    "ADV",
+			"RBR":   "ADV",
+			"RBS":   "ADV",
+			"RP":    "ADP",
+			"SYM":   "SYM",
+			"TO":    "PART",
+			"UH":    "INTJ",
+			"VB":    "VERB",
+			"VBD":   "VERB",
+			"VBG":   "VERB",
+			"VBN":   "VERB",
+			"VBP":   "VERB",
+			"VBZ":   "VERB",
+			"WDT":   "DET",
+			"WP":    "PRON",
+			"WP$":   "DET",
+			"WRB":   "ADV",
+		},
+		Words:   map[string]string{},
+		Unknown: "X",
+		Text:    map[string]string{},
+	}
+
+	brackets := map[string]string{
+		"-LRB-": "(",
+		"-RRB-": ")",
+		"-LSB-": "[",
+		"-RSB-": "]",
+		"-LCB-": "{",
+		"-RCB-": "}",
+	}
+	for token, bracket := range brackets {
+		m.Text[token] = bracket
+		m.Text[strings.ToLower(token)] = bracket
+	}
+
+	for _, word := range []string{"be", "is", "am", "are", "was", "were", "been", "being", "'s", "'re", "'m"} {
+		for _, pos := range []string{"VB", "VBD", "VBG", "VBN", "VBP", "VBZ"} {
+			m.Words[word+"/"+pos] = "AUX"
+		}
+	}
+
+	for _, word := range []string{"not", "n't"} {
+		m.Words[word+"/RB"] = "PART"
+	}
+
+	for _, word := range []string{"that", "if", "because", "while", "although", "whether", "since", "unless", "though", "whereas"} {
+		m.Words[word+"/IN"] = "SCONJ"
+	}
+
+	return m
+}
+
+// Reader returns a TagReader that maps every tag from "tr", so it can be
+// passed to ModelBuilder.FeedReaders.
+func (m *TagMapper) Reader(tr TagReader) TagReader {
+	return &mappedReader{mapper: m, reader: tr}
+}
+
+type mappedReader struct {
+	mapper *TagMapper
+	reader TagReader
+}
+
+func (mr *mappedReader) ReadTag() (Tag, error) {
+	tag, err := mr.reader.ReadTag()
+	if err != nil {
+		return tag, err
+	}
+
+	return mr.mapper.Map(tag), nil
+}
@@ -0,0 +1,148 @@
+package randtxt
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/pboyd/markov"
+)
+
+func TestTagMapper(t *testing.T) {
+	m := &TagMapper{
+		POS:   map[string]string{"NN": "NOUN"},
+		Words: map[string]string{"run/NN": "VERB"},
+	}
+
+	cases := []struct {
+		tag      Tag
+		expected Tag
+	}{
+		{Tag{Text: "poet", POS: "NN"}, Tag{Text: "poet", POS: "NOUN"}},
+		{Tag{Text: "Run", POS: "NN"}, Tag{Text: "Run", POS: "VERB"}},
+		{Tag{Text: "sing", POS: "VB"}, Tag{Text: "sing", POS: "VB"}},
+		{SentenceStartTag, SentenceStartTag},
+		{ParagraphBreakTag, ParagraphBreakTag},
+	}
+
+	for i, c := range cases {
+		actual := m.Map(c.tag)
+		if actual != c.expected {
+			t.Errorf("%d: got %v, want %v", i, actual, c.expected)
+		}
+	}
+
+	m.Unknown = "X"
+	actual := m.Map(Tag{Text: "sing", POS: "VB"})
+	if actual.POS != "X" {
+		t.Errorf("got %v, want POS X", actual)
+	}
+}
+
+func TestPennToUniversal(t *testing.T) {
+	penn := readTestFile(t, "testfiles/ion/tagged.tsv", ReadTSV)
+	universal := readTestFile(t, "testfiles/ion/universal.tsv", ReadTSV)
+
+	if len(penn) != len(universal) {
+		t.Fatalf("got %d penn tags and %d universal tags", len(penn), len(universal))
+	}
+
+	m := PennToUniversal()
+	for i, tag := range penn {
+		actual := m.Map(tag)
+		if actual != universal[i] {
+			t.Errorf("%d: got %v, want %v", i, actual, universal[i])
+		}
+	}
+}
+
+func TestPennToUniversalPOS(t *testing.T) {
+	cases := []struct {
+		penn     string
+		expected string
+	}{
+		{"DT", "DET"},
+		{"PDT", "DET"},
+		{"WDT", "DET"},
+		{"WP$", "DET"},
+		{"WP", "PRON"},
+		{"PRP", "PRON"},
+		{"PRP$", "PRON"},
+		{"WRB", "ADV"},
+		{"NNP", "PROPN"},
+		{"VBZ", "VERB"},
+	}
+
+	m := PennToUniversal()
+	for _, c := range cases {
+		actual := m.Map(Tag{Text: "word", POS: c.penn})
+		if actual.POS != c.expected {
+			t.Errorf("%s: got %s, want %s", c.penn, actual.POS, c.expected)
+		}
+	}
+}
+
+func TestPennToUniversalText(t *testing.T) {
+	tags := parseTags(strings.Split("He/PRP said/VBD -LRB-/-LRB- it/PRP costs/VBZ $/$ 5/CD -RRB-/-RRB- ./.", " "))
+
+	m := PennToUniversal()
+
+	var buf strings.Builder
+	var prev Tag
+	for _, tag := range tags {
+		tag = UniversalTagSet.Normalize(m.Map(tag), prev)
+		if tag.Text == "" {
+			continue
+		}
+
+		buf.WriteString(UniversalTagSet.Join(tag, prev))
+		prev = tag
+	}
+
+	expected := "He said it costs $5."
+	if buf.String() != expected {
+		t.Errorf("got %q, want %q", buf.String(), expected)
+	}
+
+	bracket := m.Map(Tag{Text: "-lrb-", POS: "-LRB-"})
+	if bracket != (Tag{Text: "(", POS: "PUNCT"}) {
+		t.Errorf("got %v, want (/PUNCT", bracket)
+	}
+}
+
+func TestMixedCorpus(t *testing.T) {
+	chain := markov.NewMemoryChain(0)
+	b := NewModelBuilder(chain, 2)
+	b.TagSet = UniversalTagSet
+	err := b.Feed(
+		PennToUniversal().MapAll(sliceFeed(readTestFile(t, "testfiles/ion/tagged.tsv", ReadTSV)...)),
+		sliceFeed(readTestFile(t, "testfiles/ion/universal.tsv", ReadTSV)...),
+	)
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	err = walkChain(context.Background(), chain, func(id int, value string) error {
+		for _, tag := range parseTags(strings.Split(value, " ")) {
+			if _, ok := PennToUniversal().POS[tag.POS]; ok && tag.POS != "SYM" {
+				t.Fatalf("chain has penn tag %v", tag)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	g, err := NewGenerator(chain, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("invalid chain: %v", err)
+	}
+
+	text, err := g.Paragraph(1, 3)
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+	checkParagraph(t, text, 1, 3)
+}
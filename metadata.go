@@ -0,0 +1,259 @@
+package randtxt
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pboyd/markov"
+)
+
+// metadataKey is the value of the chain node that links to the metadata
+// records. The records are the key followed by a URL encoded query, so they
+// never contain a space or a slash and can't be mistaken for tags.
+const metadataKey = "#randtxt-metadata"
+
+// ErrNoMetadata is returned by ReadMetadata when the chain doesn't have a
+// metadata record. Chains built before ModelBuilder recorded metadata don't.
+var ErrNoMetadata = errors.New("chain has no metadata")
+
+// Metadata describes how a chain was built. ModelBuilder records it in the
+// chain every time it's fed.
+type Metadata struct {
+	// NgramSize is the number of tags in each ngram.
+	NgramSize int
+
+	// TagSet is the name of the TagSet the model was built with. See
+	// TagSetName.
+	TagSet string
+
+	// Built is the time the chain was last fed.
+	Built time.Time
+
+	// Files are the names of the corpus files, from
+	// ModelBuilder.Sources.
+	Files []string
+
+	// Tokens is the number of tags that were fed, not counting boundary
+	// tags.
+	Tokens int
+
+	// Backoff and SentenceMarkers are the ModelBuilder options.
+	Backoff, SentenceMarkers bool
+
+	// TagEncoding is the version of the form the tags are stored in. It's
+	// zero for chains written before tags were escaped (see Tag.String),
+	// which MigrateChain converts.
+	TagEncoding int
+}
+
+// tagEncoding is the current Metadata.TagEncoding.
+const tagEncoding = 1
+
+// TagSetName returns the name of a TagSet. If the TagSet has a Name method
+// that's used, otherwise it's the TagSet's type.
+func TagSetName(ts TagSet) string {
+	if named, ok := ts.(interface {
+		Name() string
+	}); ok {
+		return named.Name()
+	}
+
+	return fmt.Sprintf("%T", ts)
+}
+
+// TagSetMismatchError is returned when a model is used with a different
+// TagSet than it was built with.
+type TagSetMismatchError struct {
+	// Chain is the name of the TagSet the chain was built with.
+	Chain string
+
+	// Used is the name of the TagSet that was used with it.
+	Used string
+}
+
+func (e *TagSetMismatchError) Error() string {
+	return fmt.Sprintf("chain was built with tagset %q, not %q", e.Chain, e.Used)
+}
+
+// checkTagSet returns a TagSetMismatchError if "md" names a different
+// TagSet than "ts". A nil Metadata matches anything.
+func checkTagSet(md *Metadata, ts TagSet) error {
+	if md == nil {
+		return nil
+	}
+
+	used := TagSetName(ts)
+	if md.TagSet != used {
+		return &TagSetMismatchError{
+			Chain: md.TagSet,
+			Used:  used,
+		}
+	}
+
+	return nil
+}
+
+// ReadMetadata reads the metadata from a chain built by ModelBuilder. If the
+// chain was fed more than once the records are combined. Returns
+// ErrNoMetadata if there isn't any.
+func ReadMetadata(chain markov.Chain) (*Metadata, error) {
+	key, err := chain.Find(metadataKey)
+	if err == markov.ErrNotFound {
+		return nil, ErrNoMetadata
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	links, err := chain.Links(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(links) == 0 {
+		return nil, ErrNoMetadata
+	}
+
+	ids := make([]int, len(links))
+	for i, link := range links {
+		ids[i] = link.ID
+	}
+	sort.Ints(ids)
+
+	var md *Metadata
+
+	for _, id := range ids {
+		raw, err := chain.Get(id)
+		if err != nil {
+			return nil, err
+		}
+
+		record, err := decodeMetadata(raw.(string))
+		if err != nil {
+			return nil, err
+		}
+
+		if md == nil {
+			md = record
+			continue
+		}
+
+		err = md.merge(record)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return md, nil
+}
+
+// merge adds a later record to the metadata.
+func (md *Metadata) merge(record *Metadata) error {
+	if record.NgramSize != md.NgramSize {
+		return fmt.Errorf("chain was built with ngram sizes %d and %d", md.NgramSize, record.NgramSize)
+	}
+
+	if record.TagSet != md.TagSet {
+		return fmt.Errorf("chain was built with tagsets %q and %q", md.TagSet, record.TagSet)
+	}
+
+	if record.TagEncoding != md.TagEncoding {
+		return fmt.Errorf("chain was built with tag encodings %d and %d", md.TagEncoding, record.TagEncoding)
+	}
+
+	if record.Built.After(md.Built) {
+		md.Built = record.Built
+	}
+
+	md.Files = append(md.Files, record.Files...)
+	md.Tokens += record.Tokens
+	md.Backoff = md.Backoff || record.Backoff
+	md.SentenceMarkers = md.SentenceMarkers || record.SentenceMarkers
+
+	return nil
+}
+
+// encode returns the metadata in the form it's stored in the chain.
+func (md *Metadata) encode() string {
+	v := url.Values{}
+	v.Set("size", strconv.Itoa(md.NgramSize))
+	v.Set("tagset", md.TagSet)
+	v.Set("built", md.Built.UTC().Format(time.RFC3339Nano))
+	v.Set("tokens", strconv.Itoa(md.Tokens))
+	v.Set("backoff", strconv.FormatBool(md.Backoff))
+	v.Set("markers", strconv.FormatBool(md.SentenceMarkers))
+	v.Set("encoding", strconv.Itoa(md.TagEncoding))
+	v["file"] = md.Files
+
+	// Encode uses "+" for spaces, but slashes are escaped.
+	return metadataKey + "?" + v.Encode()
+}
+
+func decodeMetadata(raw string) (*Metadata, error) {
+	if !strings.HasPrefix(raw, metadataKey+"?") {
+		return nil, fmt.Errorf("invalid metadata record %q", raw)
+	}
+
+	v, err := url.ParseQuery(raw[len(metadataKey)+1:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid metadata record: %v", err)
+	}
+
+	md := &Metadata{
+		TagSet: v.Get("tagset"),
+		Files:  v["file"],
+	}
+
+	md.NgramSize, err = strconv.Atoi(v.Get("size"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid metadata size: %v", err)
+	}
+
+	md.Tokens, err = strconv.Atoi(v.Get("tokens"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid metadata tokens: %v", err)
+	}
+
+	md.Built, err = time.Parse(time.RFC3339Nano, v.Get("built"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid metadata time: %v", err)
+	}
+
+	// Records written before the encoding was recorded don't have it.
+	if encoding := v.Get("encoding"); encoding != "" {
+		md.TagEncoding, err = strconv.Atoi(encoding)
+		if err != nil {
+			return nil, fmt.Errorf("invalid metadata tag encoding: %v", err)
+		}
+	}
+
+	md.Backoff = v.Get("backoff") == "true"
+	md.SentenceMarkers = v.Get("markers") == "true"
+
+	return md, nil
+}
+
+// writeMetadata adds a metadata record to the chain.
+func writeMetadata(chain markov.WriteChain, md *Metadata) error {
+	key, err := chain.Add(metadataKey)
+	if err != nil {
+		return err
+	}
+
+	record, err := chain.Add(md.encode())
+	if err != nil {
+		return err
+	}
+
+	return chain.Relate(key, record, 1)
+}
+
+// isMetadata tests if a chain value is part of the metadata.
+func isMetadata(value string) bool {
+	return strings.HasPrefix(value, metadataKey)
+}
@@ -0,0 +1,189 @@
+package randtxt
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pboyd/markov"
+)
+
+func TestMetadata(t *testing.T) {
+	chain := markov.NewMemoryChain(0)
+	b := NewModelBuilder(chain, 3)
+	b.SentenceMarkers = true
+	b.Sources = []string{"testfiles/ion/tagged.tsv"}
+
+	start := time.Now()
+
+	err := b.Feed(sliceFeed(readTestFile(t, "testfiles/ion/tagged.tsv", ReadTSV)...))
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	md, err := ReadMetadata(chain)
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	want := &Metadata{
+		NgramSize:       3,
+		TagSet:          "penn",
+		Built:           md.Built,
+		Files:           []string{"testfiles/ion/tagged.tsv"},
+		Tokens:          md.Tokens,
+		SentenceMarkers: true,
+		TagEncoding:     tagEncoding,
+	}
+	if !reflect.DeepEqual(md, want) {
+		t.Errorf("got %+v, want %+v", md, want)
+	}
+
+	if md.Built.Before(start.Add(-time.Second)) || md.Built.After(time.Now()) {
+		t.Errorf("got build time %v, want about %v", md.Built, start)
+	}
+
+	if md.Tokens < 4000 {
+		t.Errorf("got %d tokens, want at least 4000", md.Tokens)
+	}
+
+	// Feeding again adds to the metadata.
+	tokens := md.Tokens
+	b.Sources = []string{"more.tsv"}
+
+	err = b.Feed(sliceFeed(readTestFile(t, "testfiles/ion/tagged.tsv", ReadTSV)...))
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	md, err = ReadMetadata(chain)
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	if md.Tokens != tokens*2 {
+		t.Errorf("got %d tokens, want %d", md.Tokens, tokens*2)
+	}
+
+	if !reflect.DeepEqual(md.Files, []string{"testfiles/ion/tagged.tsv", "more.tsv"}) {
+		t.Errorf("got files %v", md.Files)
+	}
+}
+
+func TestNoMetadata(t *testing.T) {
+	chain, close := testChain(t, "testfiles/ion/trigram.mkv")
+	defer close()
+
+	_, err := ReadMetadata(chain)
+	if err != ErrNoMetadata {
+		t.Errorf("got error %v, want %v", err, ErrNoMetadata)
+	}
+
+	g, err := NewGenerator(chain, nil)
+	if err != nil {
+		t.Fatalf("invalid chain: %v", err)
+	}
+
+	if g.Metadata() != nil {
+		t.Errorf("got metadata %+v, want nil", g.Metadata())
+	}
+}
+
+func TestMetadataShortFeed(t *testing.T) {
+	chain := markov.NewMemoryChain(0)
+	b := NewModelBuilder(chain, 3)
+
+	// Too short for a single trigram, so nothing should be written.
+	err := b.Feed(sliceFeed(
+		Tag{Text: "Paul", POS: "NNP"},
+		Tag{Text: "George", POS: "NNP"},
+	))
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	_, err = ReadMetadata(chain)
+	if err != ErrNoMetadata {
+		t.Errorf("got error %v, want %v", err, ErrNoMetadata)
+	}
+
+	err = b.Feed(sliceFeed(readTestFile(t, "testfiles/ion/tagged.tsv", ReadTSV)...))
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	g, err := NewGenerator(chain, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("invalid chain: %v", err)
+	}
+
+	if g.Metadata() == nil || g.Metadata().NgramSize != 3 {
+		t.Errorf("got metadata %+v, want an ngram size of 3", g.Metadata())
+	}
+}
+
+type otherTagSet struct {
+	TagSet
+}
+
+func TestTagSetMismatch(t *testing.T) {
+	chain := markov.NewMemoryChain(0)
+	b := NewModelBuilder(chain, 2)
+	err := b.Feed(sliceFeed(readTestFile(t, "testfiles/ion/tagged.tsv", ReadTSV)...))
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	g, err := NewGenerator(chain, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("invalid chain: %v", err)
+	}
+
+	_, err = g.Paragraph(1, 2)
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	g.TagSet = otherTagSet{PennTreebankTagSet}
+
+	_, err = g.Paragraph(1, 2)
+	mismatch, ok := err.(*TagSetMismatchError)
+	if !ok {
+		t.Fatalf("got error %v, want a TagSetMismatchError", err)
+	}
+
+	if mismatch.Chain != "penn" || mismatch.Used != "randtxt.otherTagSet" {
+		t.Errorf("got %+v", mismatch)
+	}
+
+	s, err := NewScorer(chain)
+	if err != nil {
+		t.Fatalf("invalid chain: %v", err)
+	}
+	s.TagSet = g.TagSet
+
+	_, err = s.Score(nil)
+	if _, ok := err.(*TagSetMismatchError); !ok {
+		t.Errorf("got error %v from Scorer, want a TagSetMismatchError", err)
+	}
+}
+
+func TestMetadataSizeMismatch(t *testing.T) {
+	chain := markov.NewMemoryChain(0)
+	b := NewModelBuilder(chain, 2)
+	err := b.Feed(sliceFeed(readTestFile(t, "testfiles/ion/tagged.tsv", ReadTSV)...))
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	err = writeMetadata(chain, &Metadata{NgramSize: 3, TagSet: "penn"})
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	_, err = NewGenerator(chain, nil)
+	if err == nil {
+		t.Errorf("got nil error for mismatched ngram sizes")
+	}
+}
@@ -0,0 +1,225 @@
+package randtxt
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pboyd/markov"
+)
+
+// ErrLegacyTags is returned by ModelBuilder when it's asked to add to a chain
+// that was written before tags were escaped. Convert the chain with
+// MigrateChain first.
+var ErrLegacyTags = errors.New("chain was written before tags were escaped, it needs to be migrated")
+
+// ErrMigrated is returned by MigrateChain for a chain that doesn't need to
+// be migrated.
+var ErrMigrated = errors.New("chain has already been migrated")
+
+// countScale converts probabilities to the integer link counts that a chain
+// stores.
+const countScale = 1000000
+
+// probabilityCount returns the link count for a probability.
+func probabilityCount(p float64) int {
+	count := int(p*countScale + 0.5)
+	if count < 1 {
+		count = 1
+	}
+	return count
+}
+
+// MigrateChain writes "src", a chain written before tags were escaped (see
+// Tag.String), to "dest" with every tag escaped. The values are added in the
+// same order, but a chain on disk may give them different IDs, so an Index
+// must be rebuilt.
+//
+// "dest" is given metadata that records the new encoding. If "src" doesn't
+// have any, it's assumed to have been built with PennTreebankTagSet.
+//
+// A chain only stores the probabilities of its links, so "dest" is given
+// counts in proportion to them, scaled to a million. Adding to "dest" later
+// with ModelBuilder counts each new ngram once, so new text has little effect
+// on the existing probabilities; rebuild the chain from the corpus instead if
+// that matters. Returns ErrMigrated if the metadata of "src" shows that it
+// doesn't need to be migrated.
+func MigrateChain(dest markov.WriteChain, src markov.Chain) error {
+	return MigrateChainContext(context.Background(), dest, src)
+}
+
+// MigrateChainContext is like MigrateChain but stops and returns the
+// context's error if the context is cancelled first.
+func MigrateChainContext(ctx context.Context, dest markov.WriteChain, src markov.Chain) error {
+	md, err := ReadMetadata(src)
+	if err == nil && md.TagEncoding >= tagEncoding {
+		return ErrMigrated
+	}
+	if err != nil && err != ErrNoMetadata {
+		return err
+	}
+	hasMetadata := err == nil
+
+	var ids []int
+	destIDs := map[int]int{}
+
+	err = walkValues(ctx, src, func(id int, value string) error {
+		migrated, err := migrateValue(value)
+		if err != nil {
+			return err
+		}
+
+		destID, err := dest.Add(migrated)
+		if err != nil {
+			return err
+		}
+
+		ids = append(ids, id)
+		destIDs[id] = destID
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		links, err := src.Links(id)
+		if err != nil {
+			return err
+		}
+
+		for _, link := range links {
+			if link.Probability <= 0 {
+				continue
+			}
+
+			child, ok := destIDs[link.ID]
+			if !ok {
+				continue
+			}
+
+			err = dest.Relate(destIDs[id], child, probabilityCount(link.Probability))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// The metadata records were migrated with the rest of the values.
+	// Otherwise "dest" needs one, or it would look like a legacy chain.
+	if hasMetadata {
+		return nil
+	}
+
+	size, err := inspectChain(src)
+	if err != nil {
+		return err
+	}
+
+	markers, err := hasSentenceMarkers(src)
+	if err != nil {
+		return err
+	}
+
+	return writeMetadata(dest, &Metadata{
+		NgramSize:       size,
+		TagSet:          TagSetName(PennTreebankTagSet),
+		Built:           time.Now(),
+		SentenceMarkers: markers,
+		TagEncoding:     tagEncoding,
+	})
+}
+
+// migrateValue returns a chain value with its tags escaped.
+func migrateValue(value string) (string, error) {
+	if !isMetadata(value) {
+		return migrateLegacy(value), nil
+	}
+
+	if value == metadataKey {
+		return value, nil
+	}
+
+	md, err := decodeMetadata(value)
+	if err != nil {
+		return "", err
+	}
+
+	md.TagEncoding = tagEncoding
+	return md.encode(), nil
+}
+
+// migrateLegacy escapes the tags in an ngram written before tags were
+// escaped. Such tags never contained a space, and the POS never contained a
+// "/".
+func migrateLegacy(ngram string) string {
+	grams := strings.Split(ngram, " ")
+	for i, gram := range grams {
+		if parseTag(gram).IsBoundary() {
+			continue
+		}
+
+		j := strings.LastIndexByte(gram, '/')
+		if j < 0 {
+			continue
+		}
+
+		tag := Tag{Text: gram[:j], POS: gram[j+1:]}
+		grams[i] = tag.String()
+	}
+
+	return strings.Join(grams, " ")
+}
+
+// CheckTagEncoding returns ErrLegacyTags if the chain was written before tags
+// were escaped. That's usually recorded in the chain's metadata. Chains
+// without metadata, like those built before it was recorded, are checked tag
+// by tag, which reads the whole chain.
+//
+// ModelBuilder checks the chain it writes to, once, if it can be read. Use
+// CheckTagEncoding before adding to a chain some other way, such as by
+// building a chain in memory and copying it.
+func CheckTagEncoding(chain markov.Chain) error {
+	md, err := ReadMetadata(chain)
+	if err == ErrNoMetadata {
+		return checkLegacyValues(chain)
+	}
+	if err != nil {
+		return err
+	}
+
+	if md.TagEncoding < tagEncoding {
+		return ErrLegacyTags
+	}
+
+	return nil
+}
+
+// checkLegacyValues returns ErrLegacyTags if any tag in the chain isn't in the
+// form Tag.String writes. A legacy chain without words that need escaping is
+// the same as an escaped one, so it passes.
+func checkLegacyValues(chain markov.Chain) error {
+	// A new chain on disk can't be read until something is written.
+	root, err := chain.Get(0)
+	if err == io.EOF || err == nil && root == nil {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return walkChain(context.Background(), chain, func(id int, value string) error {
+		for _, gram := range strings.Split(value, " ") {
+			if parseTag(gram).String() != gram {
+				return ErrLegacyTags
+			}
+		}
+		return nil
+	})
+}
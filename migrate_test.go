@@ -0,0 +1,247 @@
+package randtxt
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/pboyd/markov"
+)
+
+// legacyChain returns a bigram chain in the form ModelBuilder wrote before
+// tags were escaped. If "metadata" is set it has a metadata record from then.
+func legacyChain(t *testing.T, metadata bool) markov.ReadWriteChain {
+	t.Helper()
+
+	chain := markov.NewMemoryChain(0)
+
+	relate := func(parent, child string, count int) {
+		parentID, err := chain.Add(parent)
+		if err != nil {
+			t.Fatalf("got error %v, want nil", err)
+		}
+
+		childID, err := chain.Add(child)
+		if err != nil {
+			t.Fatalf("got error %v, want nil", err)
+		}
+
+		err = chain.Relate(parentID, childID, count)
+		if err != nil {
+			t.Fatalf("got error %v, want nil", err)
+		}
+	}
+
+	ngrams := []string{"cats/NNS and/or/CC", "and/or/CC 50%/NN", "50%/NN ./.", "./. cats/NNS"}
+	for _, ngram := range ngrams {
+		relate(ngram, strings.Split(ngram, " ")[1], 1)
+	}
+
+	relate("and/or/CC 50%/NN", "cats/NNS", 3)
+
+	for _, ngram := range ngrams {
+		relate(strings.Split(ngram, " ")[1], ngram, 1)
+	}
+
+	if !metadata {
+		return chain
+	}
+
+	relate(metadataKey, metadataKey+"?backoff=false&built=2018-01-01T00%3A00%3A00Z&markers=false&size=2&tagset=penn&tokens=4", 1)
+
+	return chain
+}
+
+func TestMigrateChain(t *testing.T) {
+	src := legacyChain(t, true)
+
+	b := NewModelBuilder(src, 2)
+	err := b.Feed(sliceFeed(Tag{Text: "dogs", POS: "NNS"}))
+	if err != ErrLegacyTags {
+		t.Errorf("got error %v feeding a legacy chain, want ErrLegacyTags", err)
+	}
+
+	dest := markov.NewMemoryChain(0)
+	err = MigrateChain(dest, src)
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	for id, expected := range []string{"cats/NNS and%2For/CC", "and%2For/CC"} {
+		value, err := dest.Get(id)
+		if err != nil || value != expected {
+			t.Errorf("got %v, %v for ID %d, want %q", value, err, id, expected)
+		}
+	}
+
+	// The probabilities are kept.
+	id, err := dest.Find("and%2For/CC 50%25/NN")
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	links, err := dest.Links(id)
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	for _, link := range links {
+		value, _ := dest.Get(link.ID)
+
+		expected := 0.25
+		if value == "cats/NNS" {
+			expected = 0.75
+		}
+
+		if math.Abs(link.Probability-expected) > 1e-6 {
+			t.Errorf("got probability %f for %v, want %f", link.Probability, value, expected)
+		}
+	}
+
+	md, err := ReadMetadata(dest)
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	if md.TagEncoding != tagEncoding || md.Tokens != 4 {
+		t.Errorf("got metadata %+v", md)
+	}
+
+	g, err := NewGenerator(dest, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("invalid chain: %v", err)
+	}
+
+	var text string
+	for i := 0; i < 10; i++ {
+		paragraph, err := g.Paragraph(2, 3)
+		if err != nil {
+			t.Fatalf("got error %v, want nil", err)
+		}
+		text += paragraph
+	}
+
+	if !strings.Contains(text, " and/or ") || !strings.Contains(text, " 50%") {
+		t.Errorf("%q doesn't have the unescaped words", text)
+	}
+
+	if strings.Contains(text, "%2F") || strings.Contains(text, "%25") {
+		t.Errorf("%q has escaped words", text)
+	}
+
+	err = MigrateChain(markov.NewMemoryChain(0), dest)
+	if err != ErrMigrated {
+		t.Errorf("got error %v migrating twice, want ErrMigrated", err)
+	}
+
+	// The migrated chain can be added to.
+	b = NewModelBuilder(dest, 2)
+	err = b.Feed(sliceFeed(Tag{Text: "dogs", POS: "NNS"}, Tag{Text: "and/or", POS: "CC"}))
+	if err != nil {
+		t.Errorf("got error %v feeding a migrated chain, want nil", err)
+	}
+}
+
+func TestCheckTagEncoding(t *testing.T) {
+	err := CheckTagEncoding(markov.NewMemoryChain(0))
+	if err != nil {
+		t.Errorf("got error %v for an empty chain, want nil", err)
+	}
+
+	// Chains from before metadata was recorded.
+	src := legacyChain(t, false)
+	err = CheckTagEncoding(src)
+	if err != ErrLegacyTags {
+		t.Errorf("got error %v, want ErrLegacyTags", err)
+	}
+
+	b := NewModelBuilder(src, 2)
+	err = b.Feed(sliceFeed(Tag{Text: "dogs", POS: "NNS"}))
+	if err != ErrLegacyTags {
+		t.Errorf("got error %v feeding a legacy chain, want ErrLegacyTags", err)
+	}
+
+	// This one doesn't have any words that need escaping.
+	chain, close := testChain(t, "testfiles/ion/trigram.mkv")
+	defer close()
+
+	err = CheckTagEncoding(chain)
+	if err != nil {
+		t.Errorf("got error %v, want nil", err)
+	}
+}
+
+// countingChain counts the values read from a chain.
+type countingChain struct {
+	markov.ReadWriteChain
+	gets int
+}
+
+func (c *countingChain) Get(id int) (interface{}, error) {
+	c.gets++
+	return c.ReadWriteChain.Get(id)
+}
+
+func TestCheckTagEncodingOnce(t *testing.T) {
+	chain := &countingChain{ReadWriteChain: markov.NewMemoryChain(0)}
+	_, err := chain.Add("cats/NNS ./.")
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	// Without tokens the builder doesn't write metadata, so each Feed
+	// would have to walk the chain again.
+	b := NewModelBuilder(chain, 2)
+	err = b.Feed(sliceFeed())
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	gets := chain.gets
+	if gets == 0 {
+		t.Fatalf("the chain wasn't checked")
+	}
+
+	err = b.Feed(sliceFeed())
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	if chain.gets != gets {
+		t.Errorf("got %d reads checking the chain again, want none", chain.gets-gets)
+	}
+}
+
+func TestMigrateChainWithoutMetadata(t *testing.T) {
+	dest := markov.NewMemoryChain(0)
+	err := MigrateChain(dest, legacyChain(t, false))
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	md, err := ReadMetadata(dest)
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	if md.TagEncoding != tagEncoding || md.NgramSize != 2 || md.TagSet != "penn" {
+		t.Errorf("got metadata %+v", md)
+	}
+
+	err = CheckTagEncoding(dest)
+	if err != nil {
+		t.Errorf("got error %v checking the migrated chain, want nil", err)
+	}
+
+	// A second run mustn't escape the tags again.
+	err = MigrateChain(markov.NewMemoryChain(0), dest)
+	if err != ErrMigrated {
+		t.Errorf("got error %v migrating twice, want ErrMigrated", err)
+	}
+
+	_, err = dest.Find("cats/NNS and%2For/CC")
+	if err != nil {
+		t.Errorf("got error %v finding a migrated ngram", err)
+	}
+}
@@ -1,6 +1,7 @@
 package randtxt
 
 import (
+	"context"
 	"errors"
 	"math/rand"
 	"strings"
@@ -12,53 +13,90 @@ import (
 //
 // Model is a lower-level interface. Generator is the recommended way to
 // generate text.
+//
+// A Model is not safe for concurrent use.
 type Model struct {
 	chain   markov.Chain
+	rand    *rand.Rand
+	seeds   []int
 	current string
 	past    []string
+
+	// Sampling controls how Step chooses the next tag.
+	Sampling Sampling
+
+	// Steering biases Step toward keywords.
+	Steering Steering
+
+	// Estimator, if set, replaces the chain's probabilities in NextTags
+	// with smoothed estimates. It doesn't affect Step.
+	Estimator Estimator
+
+	// TagSet decides where sentences end in BeamSearch. NewModel sets it
+	// to the TagSet named in the chain's metadata, or PennTreebankTagSet.
+	TagSet TagSet
 }
 
+// ErrDeadEnd is returned when the model reaches a tag that nothing follows,
+// and restarting it at a random seed doesn't find one that something does.
+var ErrDeadEnd = errors.New("model reached a dead end in the chain")
+
+// maxReseeds is the number of random seeds the model tries after reaching a
+// dead end.
+const maxReseeds = 100
+
 // NewModel initializes a model from a chain. "seed" is used as the starting
 // point. If "seed" is blank a random seed is chosen.
-func NewModel(chain markov.Chain, seed string) (*Model, error) {
+//
+// "r" is the source of randomness for the model. Two models with identically
+// seeded sources will produce the same output. If "r" is nil a source seeded
+// with the current time is used.
+func NewModel(chain markov.Chain, seed string, r *rand.Rand) (*Model, error) {
+	md, err := ReadMetadata(chain)
+	if err == ErrNoMetadata {
+		md, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Model{
+		chain:  chain,
+		rand:   newRand(r),
+		TagSet: chainTagSet(md),
+	}
+
 	if seed == "" {
-		var err error
-		seed, err = randomSeed(chain)
+		seed, err = m.randomSeed(context.Background())
 		if err != nil {
 			return nil, err
 		}
 	}
 
 	// Make sure the seed exists.
-	_, err := chain.Find(seed)
+	_, err = chain.Find(seed)
 	if err != nil {
 		return nil, err
 	}
 
-	past := strings.Split(seed, " ")
-	return &Model{
-		chain: chain,
-		past:  past,
-	}, nil
+	m.past = strings.Split(seed, " ")
+	return m, nil
 }
 
-func randomSeed(chain markov.Chain) (string, error) {
-	size, err := inspectChain(chain)
-	if err != nil {
-		return "", nil
-	}
-
-	for {
-		raw, err := markov.Random(chain)
+func (m *Model) randomSeed(ctx context.Context) (string, error) {
+	if m.seeds == nil {
+		size, err := inspectChain(m.chain)
 		if err != nil {
 			return "", err
 		}
 
-		seed := strings.Split(raw.(string), " ")
-		if len(seed) == size {
-			return raw.(string), nil
+		m.seeds, err = seedIDs(ctx, m.chain, size)
+		if err != nil {
+			return "", err
 		}
 	}
+
+	return randomSeed(m.chain, m.seeds, m.rand)
 }
 
 // Current returns the word and POS tag that the model is currently at..
@@ -69,7 +107,11 @@ func (m *Model) Current() Tag {
 // NextTags returns a list of tags that could be next along with their
 // probabilities.
 func (m *Model) NextTags() ([]TagProbability, error) {
-	links, err := m.nextLinks()
+	if m.Estimator != nil {
+		return m.estimatedTags(), nil
+	}
+
+	links, err := m.nextLinks(context.Background())
 	if err != nil {
 		return nil, err
 	}
@@ -90,9 +132,34 @@ func (m *Model) NextTags() ([]TagProbability, error) {
 	return tp, nil
 }
 
+// estimatedTags returns every tag in the Estimator's vocabulary that could be
+// next.
+func (m *Model) estimatedTags() []TagProbability {
+	context := parseTags(m.past)
+
+	var tp []TagProbability
+	for _, tag := range m.Estimator.Vocabulary() {
+		p := m.Estimator.Probability(context, tag)
+		if p > 0 {
+			tp = append(tp, TagProbability{
+				raw:         tag.String(),
+				Probability: p,
+			})
+		}
+	}
+
+	return tp
+}
+
 // Step advances the model.
 func (m *Model) Step() error {
-	next, err := m.pickNext()
+	return m.StepContext(context.Background())
+}
+
+// StepContext advances the model. It returns the context's error if the
+// context is cancelled before the next tag is found.
+func (m *Model) StepContext(ctx context.Context) error {
+	next, err := m.pickNext(ctx)
 	if err != nil {
 		return err
 	}
@@ -107,57 +174,161 @@ func (m *Model) Step() error {
 	return nil
 }
 
-func (m *Model) pickNext() (string, error) {
-	links, err := m.nextLinks()
+func (m *Model) pickNext(ctx context.Context) (string, error) {
+	links, err := m.nextLinks(ctx)
 	if err != nil {
 		return "", err
 	}
 
-	index := rand.Float64()
-	var passed float64
+	links, err = m.steer(ctx, links)
+	if err != nil {
+		return "", err
+	}
 
-	for _, link := range links {
-		passed += link.Probability
-		if passed > index {
-			raw, err := m.chain.Get(link.ID)
-			if err != nil {
-				return "", err
-			}
-
-			return raw.(string), nil
+	link := m.Sampling.pick(links, m.rand)
+
+	raw, err := m.chain.Get(link.ID)
+	if err != nil {
+		return "", err
+	}
+
+	return raw.(string), nil
+}
+
+// nextLinks returns the links from the longest context that has any.
+//
+// If the model was built with ModelBuilder.Backoff, shorter contexts are tried
+// when the full context is unknown or has no continuations. If none of them
+// have any, and the chain has sentence markers, the model continues as if a
+// sentence had just started.
+func (m *Model) nextLinks(ctx context.Context) ([]markov.Link, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	links, err := m.backoffLinks(m.past)
+	if err != nil || len(links) > 0 {
+		return links, err
+	}
+
+	// Only a chain built with both Backoff and SentenceMarkers has
+	// continuations for "<s>" alone.
+	links, err = m.contextLinks([]string{SentenceStartTag.String()})
+	if err != nil || len(links) > 0 {
+		return links, err
+	}
+
+	// If the chain ends in a unique phrase, and there's nothing to back
+	// off to, the chain will end. Restart it at a random point. This
+	// isn't ideal, since it may be mid-sentence.
+	for i := 0; i < maxReseeds; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		err = m.reseed(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		links, err = m.backoffLinks(m.past)
+		if err != nil || len(links) > 0 {
+			return links, err
 		}
 	}
 
-	return "", errors.New("failed")
+	return nil, ErrDeadEnd
+}
+
+// backoffLinks returns the links from the longest suffix of "past" that has
+// any. Returns nil if none of them do.
+func (m *Model) backoffLinks(past []string) ([]markov.Link, error) {
+	return backoffLinks(m.chain, past)
 }
 
-func (m *Model) nextLinks() ([]markov.Link, error) {
-	id, err := m.chain.Find(strings.Join(m.past, " "))
+// contextLinks returns the links to the tags that follow "context". Returns
+// nil if the context isn't in the chain.
+func (m *Model) contextLinks(context []string) ([]markov.Link, error) {
+	return contextLinks(m.chain, context, len(m.past))
+}
+
+// backoffLinks returns the links from the longest suffix of "past" that has
+// any in a chain of ngrams the size of "past". Returns nil if none of them do.
+func backoffLinks(chain markov.Chain, past []string) ([]markov.Link, error) {
+	for start := range past {
+		links, err := contextLinks(chain, past[start:], len(past))
+		if err != nil {
+			return nil, err
+		}
+
+		if len(links) > 0 {
+			return links, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// contextLinks returns the links to the tags that follow "context" in a chain
+// of ngrams of "size". Returns nil if the context isn't in the chain.
+func contextLinks(chain markov.Chain, context []string, size int) ([]markov.Link, error) {
+	id, err := chain.Find(strings.Join(context, " "))
+	if err == markov.ErrNotFound {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	links, err := m.chain.Links(id)
+	links, err := chain.Links(id)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(links) == 0 {
-		// If the chain ends in a unique phrase the chain will end.
-		// Restart it at a random point. This isn't ideal, since it may
-		// be mid-sentence.
-		err = m.reseed()
+	if len(context) == 1 && size > 1 {
+		// A single tag is also linked to every longer ngram that
+		// ends with it. Only the links to other tags are unigram
+		// continuations.
+		return tagLinks(chain, links)
+	}
+
+	return links, nil
+}
+
+// tagLinks filters out links to ngrams and rescales the probabilities of the
+// remaining links.
+func tagLinks(chain markov.Chain, links []markov.Link) ([]markov.Link, error) {
+	filtered := make([]markov.Link, 0, len(links))
+	total := 0.0
+
+	for _, link := range links {
+		raw, err := chain.Get(link.ID)
 		if err != nil {
 			return nil, err
 		}
-		return m.nextLinks()
+
+		value, ok := raw.(string)
+		if !ok || strings.Contains(value, " ") {
+			continue
+		}
+
+		filtered = append(filtered, link)
+		total += link.Probability
 	}
 
-	return links, nil
+	normalize(filtered, total)
+	return filtered, nil
+}
+
+// normalize divides the probability of every link by "total".
+func normalize(links []markov.Link, total float64) {
+	for i := range links {
+		links[i].Probability /= total
+	}
 }
 
-func (m *Model) reseed() error {
-	seed, err := randomSeed(m.chain)
+func (m *Model) reseed(ctx context.Context) error {
+	seed, err := m.randomSeed(ctx)
 	if err != nil {
 		return err
 	}
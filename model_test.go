@@ -1,17 +1,19 @@
 package randtxt
 
 import (
+	"context"
 	"math/rand"
 	"reflect"
 	"testing"
-	"time"
+
+	"github.com/pboyd/markov"
 )
 
 func TestModel(t *testing.T) {
 	chain, close := testChain(t, "testfiles/ion/trigram.mkv")
 	defer close()
 
-	model, err := NewModel(chain, "")
+	model, err := NewModel(chain, "", nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -43,11 +45,10 @@ func TestModel(t *testing.T) {
 }
 
 func TestUnigramModel(t *testing.T) {
-	rand.Seed(time.Now().Unix())
 	chain, close := testChain(t, "testfiles/ion/unigram.mkv")
 	defer close()
 
-	model, err := NewModel(chain, "")
+	model, err := NewModel(chain, "", nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -77,3 +78,143 @@ func TestUnigramModel(t *testing.T) {
 		model.Step()
 	}
 }
+
+func TestModelRand(t *testing.T) {
+	chain, close := testChain(t, "testfiles/ion/trigram.mkv")
+	defer close()
+
+	walk := func(seed int64) []Tag {
+		model, err := NewModel(chain, "", rand.New(rand.NewSource(seed)))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		tags := make([]Tag, 50)
+		for i := range tags {
+			err := model.Step()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tags[i] = model.Current()
+		}
+
+		return tags
+	}
+
+	first := walk(42)
+	second := walk(42)
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("got different tags from the same seed")
+	}
+}
+
+func TestModelBackoff(t *testing.T) {
+	chain := markov.NewMemoryChain(100)
+	b := NewModelBuilder(chain, 3)
+	b.Backoff = true
+
+	err := b.Feed(tagFeed(100))
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+
+	cases := []struct {
+		past     []string
+		expected string
+	}{
+		{
+			past:     []string{"Z/NNP", "B/NNP", "C/NNP"},
+			expected: "D/NNP",
+		},
+		{
+			past:     []string{"Z/NNP", "Y/NNP", "E/NNP"},
+			expected: "F/NNP",
+		},
+	}
+
+	for _, c := range cases {
+		model, err := NewModel(chain, "", rand.New(rand.NewSource(1)))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		model.past = c.past
+
+		err = model.Step()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		actual := model.Current().String()
+		if actual != c.expected {
+			t.Errorf("%v: got %q, want %q", c.past, actual, c.expected)
+		}
+	}
+}
+
+func TestStepContext(t *testing.T) {
+	chain, close := testChain(t, "testfiles/ion/trigram.mkv")
+	defer close()
+
+	model, err := NewModel(chain, "", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = model.StepContext(ctx)
+	if err != context.Canceled {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestModelDeadEnd(t *testing.T) {
+	chain := markov.NewMemoryChain(0)
+	b := NewModelBuilder(chain, 2)
+	b.Backoff = true
+	b.SentenceMarkers = true
+
+	err := b.Feed(sliceFeed(
+		Tag{Text: "The", POS: "DT"},
+		Tag{Text: "cat", POS: "NN"},
+		Tag{Text: "sat", POS: "VBD"},
+		Tag{Text: ".", POS: "."},
+	))
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+
+	// Nothing follows the last sentence end, so the model starts another
+	// sentence.
+	model, err := NewModel(chain, "./. </s>", rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	next, err := model.NextTags()
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+
+	if len(next) != 1 || next[0].Tag() != (Tag{Text: "the", POS: "DT"}) {
+		t.Errorf("got %v, want the/DT", next)
+	}
+
+	// Without anything to back off to, the model gives up.
+	chain = markov.NewMemoryChain(0)
+	_, err = chain.Add("dead/JJ end/NN")
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+
+	model, err = NewModel(chain, "dead/JJ end/NN", rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = model.Step()
+	if err != ErrDeadEnd {
+		t.Errorf("got error %v, want ErrDeadEnd", err)
+	}
+}
@@ -0,0 +1,269 @@
+package randtxt
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strings"
+
+	"github.com/pboyd/markov"
+)
+
+// ErrPhraseNotFound is returned when no path through the chain matches a
+// phrase.
+var ErrPhraseNotFound = errors.New("phrase not found in chain")
+
+// FindSeed returns a seed for NewModel that ends with the words in "phrase".
+//
+// Words are matched by their text, ignoring case and part of speech. If the
+// phrase is longer than the ngrams in the chain, the rest of the phrase is
+// matched by following links from the first ngram. When more than one path
+// matches, one is picked at random, weighted by how often it occurs.
+//
+// If "r" is nil a source seeded with the current time is used.
+func FindSeed(chain markov.Chain, phrase string, r *rand.Rand) (string, error) {
+	size, err := inspectChain(chain)
+	if err != nil {
+		return "", err
+	}
+
+	match, err := matchPhrase(context.Background(), chain, nil, size, phrase, newRand(r))
+	if err != nil {
+		return "", err
+	}
+
+	return match.seed, nil
+}
+
+// StartWith makes the next text the generator writes begin with "phrase".
+// Returns ErrPhraseNotFound if the phrase isn't in the chain. See FindSeed for
+// how the phrase is matched.
+//
+// The generator's Index is used to find the phrase if it's set, otherwise the
+// whole chain is searched.
+func (g *Generator) StartWith(phrase string) error {
+	match, err := matchPhrase(context.Background(), g.chain, g.Index, g.size, phrase, g.rand)
+	if err != nil {
+		return err
+	}
+
+	g.start = match
+	return nil
+}
+
+// phraseMatch is a path through the chain that matches a phrase.
+type phraseMatch struct {
+	// seed is the ngram at the end of the phrase.
+	seed string
+
+	// tags are the tags that matched the phrase.
+	tags []Tag
+}
+
+func matchPhrase(ctx context.Context, chain markov.Chain, ix *Index, size int, phrase string, r *rand.Rand) (*phraseMatch, error) {
+	words := splitPhrase(phrase)
+	if len(words) == 0 {
+		return nil, ErrPhraseNotFound
+	}
+
+	head := words
+	if len(head) > size {
+		head = words[:size]
+	}
+
+	candidates, err := phraseCandidates(ctx, chain, ix, size, head)
+	if err != nil {
+		return nil, err
+	}
+
+	for len(candidates) > 0 {
+		picked := Sampling{}.pick(candidates, r)
+
+		raw, err := chain.Get(picked.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		match, err := extendPhrase(chain, raw.(string), len(head), words[len(head):], r)
+		if err != nil || match != nil {
+			return match, err
+		}
+
+		// The rest of the phrase doesn't follow this ngram.
+		for i, c := range candidates {
+			if c.ID == picked.ID {
+				candidates = append(candidates[:i], candidates[i+1:]...)
+				break
+			}
+		}
+	}
+
+	return nil, ErrPhraseNotFound
+}
+
+// phraseCandidates returns links to the ngrams that end with "words". The
+// probability of each link is the weight it should be picked with.
+//
+// If "ix" is nil the whole chain is searched.
+func phraseCandidates(ctx context.Context, chain markov.Chain, ix *Index, size int, words []string) ([]markov.Link, error) {
+	var candidates []markov.Link
+	var lastTags []string
+
+	match := func(id int, value string) error {
+		grams := strings.Split(value, " ")
+		if len(grams) != size {
+			return nil
+		}
+
+		suffix := grams[size-len(words):]
+		for i, gram := range suffix {
+			tag := parseTag(gram)
+			if tag.IsBoundary() || !strings.EqualFold(tag.Text, words[i]) {
+				return nil
+			}
+		}
+
+		candidates = append(candidates, markov.Link{ID: id, Probability: 1})
+		lastTags = append(lastTags, grams[size-1])
+		return nil
+	}
+
+	var err error
+	if ix == nil {
+		err = walkChain(ctx, chain, match)
+	} else {
+		err = eachIndexed(ctx, chain, ix.Word(words[len(words)-1]), match)
+	}
+	if err != nil || size == 1 {
+		// Single tags are weighted equally.
+		return candidates, err
+	}
+
+	// How often an ngram occurs is only known relative to the other
+	// ngrams that end with the same tag, since that tag links to each of
+	// them.
+	weights := map[string]map[int]float64{}
+
+	for i, last := range lastTags {
+		w, ok := weights[last]
+		if !ok {
+			w, err = linkProbabilities(chain, last)
+			if err != nil {
+				return nil, err
+			}
+			weights[last] = w
+		}
+
+		if p, ok := w[candidates[i].ID]; ok {
+			candidates[i].Probability = p
+		}
+	}
+
+	return candidates, nil
+}
+
+// linkProbabilities returns the probability of each link from "value", by ID.
+func linkProbabilities(chain markov.Chain, value string) (map[int]float64, error) {
+	probs := map[int]float64{}
+
+	id, err := chain.Find(value)
+	if err == markov.ErrNotFound {
+		return probs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	links, err := chain.Links(id)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, link := range links {
+		probs[link.ID] = link.Probability
+	}
+
+	return probs, nil
+}
+
+// extendPhrase follows links from "seed" that match "words". "matched" is the
+// number of tags at the end of the seed that are part of the phrase. Returns
+// nil if the words can't be followed.
+func extendPhrase(chain markov.Chain, seed string, matched int, words []string, r *rand.Rand) (*phraseMatch, error) {
+	past := strings.Split(seed, " ")
+
+	tags := make([]Tag, 0, matched+len(words))
+	for _, gram := range past[len(past)-matched:] {
+		tags = append(tags, parseTag(gram))
+	}
+
+	for _, word := range words {
+		id, err := chain.Find(strings.Join(past, " "))
+		if err == markov.ErrNotFound {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		links, err := chain.Links(id)
+		if err != nil {
+			return nil, err
+		}
+
+		next := make([]markov.Link, 0, len(links))
+		for _, link := range links {
+			raw, err := chain.Get(link.ID)
+			if err != nil {
+				return nil, err
+			}
+
+			value := raw.(string)
+			if strings.Contains(value, " ") {
+				continue
+			}
+
+			if strings.EqualFold(parseTag(value).Text, word) {
+				next = append(next, link)
+			}
+		}
+
+		if len(next) == 0 {
+			return nil, nil
+		}
+
+		raw, err := chain.Get(Sampling{}.pick(next, r).ID)
+		if err != nil {
+			return nil, err
+		}
+
+		gram := raw.(string)
+		copy(past, past[1:])
+		past[len(past)-1] = gram
+		tags = append(tags, parseTag(gram))
+	}
+
+	return &phraseMatch{
+		seed: strings.Join(past, " "),
+		tags: tags,
+	}, nil
+}
+
+// splitPhrase splits plain text into words. Punctuation at the end of a word
+// is split into a word of its own, since taggers treat it that way.
+func splitPhrase(phrase string) []string {
+	var words []string
+
+	for _, field := range strings.Fields(phrase) {
+		trimmed := strings.TrimRight(field, ".,;:?!")
+		if trimmed != "" {
+			words = append(words, trimmed)
+		}
+
+		for _, punct := range field[len(trimmed):] {
+			words = append(words, string(punct))
+		}
+	}
+
+	return words
+}
@@ -0,0 +1,93 @@
+package randtxt
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFindSeed(t *testing.T) {
+	chain, close := testChain(t, "testfiles/ion/trigram.mkv")
+	defer close()
+
+	cases := map[string][]string{
+		"the poet":            {"the", "poet"},
+		"THE POET":            {"the", "poet"},
+		"the poet is a light": {"is", "a", "light"},
+		"Ion.":                {"ion", "."},
+	}
+
+	r := rand.New(rand.NewSource(1))
+
+	for phrase, expected := range cases {
+		seed, err := FindSeed(chain, phrase, r)
+		if err != nil {
+			t.Errorf("%q: got error %v", phrase, err)
+			continue
+		}
+
+		grams := strings.Split(seed, " ")
+		grams = grams[len(grams)-len(expected):]
+
+		actual := make([]string, len(grams))
+		for i, gram := range grams {
+			actual[i] = strings.ToLower(parseTag(gram).Text)
+		}
+
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%q: got seed %q, want it to end with %v", phrase, seed, expected)
+		}
+
+		_, err = NewModel(chain, seed, r)
+		if err != nil {
+			t.Errorf("%q: got error %v from NewModel", phrase, err)
+		}
+	}
+}
+
+func TestFindSeedNotFound(t *testing.T) {
+	chain, close := testChain(t, "testfiles/ion/trigram.mkv")
+	defer close()
+
+	for _, phrase := range []string{"", "purple cow", "the poet is a purple"} {
+		_, err := FindSeed(chain, phrase, nil)
+		if err != ErrPhraseNotFound {
+			t.Errorf("%q: got error %v, want %v", phrase, err, ErrPhraseNotFound)
+		}
+	}
+}
+
+func TestStartWith(t *testing.T) {
+	chain, close := testChain(t, "testfiles/ion/trigram.mkv")
+	defer close()
+
+	g, err := NewGenerator(chain, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("invalid chain: %v", err)
+	}
+
+	for _, phrase := range []string{"the poet", "the poet is a light"} {
+		err := g.StartWith(phrase)
+		if err != nil {
+			t.Fatalf("%q: got error %v", phrase, err)
+		}
+
+		text, err := g.Paragraph(1, 3)
+		if err != nil {
+			t.Fatalf("got error %v, want nil", err)
+		}
+
+		if !strings.HasPrefix(strings.ToLower(text), phrase) {
+			t.Errorf("%q: got %q", phrase, text)
+		}
+	}
+}
+
+func TestSplitPhrase(t *testing.T) {
+	actual := splitPhrase(" Welcome,  Ion?! ")
+	expected := []string{"Welcome", ",", "Ion", "?", "!"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("got %v, want %v", actual, expected)
+	}
+}
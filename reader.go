@@ -0,0 +1,165 @@
+package randtxt
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxLineLength is the longest line that the TagReaders accept.
+const maxLineLength = 1 << 24
+
+// TagReader reads tags from tagged text, one at a time.
+type TagReader interface {
+	// ReadTag returns the next tag. It returns io.EOF after the last
+	// one. Format and read errors are returned as a *ReadError.
+	ReadTag() (Tag, error)
+}
+
+// ReadError is an error reading tagged text, with its position.
+type ReadError struct {
+	// File is the name of the file, if it's known.
+	File string
+
+	// Line is the line number of the error, starting from 1.
+	Line int
+
+	Err error
+}
+
+func (e *ReadError) Error() string {
+	if e.File == "" {
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	}
+
+	return fmt.Sprintf("%s:%d: %v", e.File, e.Line, e.Err)
+}
+
+// lineScanner reads lines and counts them, for the TagReaders.
+type lineScanner struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func newLineScanner(r io.Reader) *lineScanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineLength)
+	return &lineScanner{scanner: scanner}
+}
+
+// next returns the next line. It returns io.EOF after the last line. Read
+// errors are returned as a ReadError for the file "name".
+func (s *lineScanner) next(name string) (string, error) {
+	if !s.scanner.Scan() {
+		if err := s.scanner.Err(); err != nil {
+			return "", &ReadError{
+				File: name,
+				Line: s.line + 1,
+				Err:  err,
+			}
+		}
+		return "", io.EOF
+	}
+
+	s.line++
+	return s.scanner.Text(), nil
+}
+
+// errorf returns a ReadError for the current line of the file "name".
+func (s *lineScanner) errorf(name, format string, args ...interface{}) error {
+	return &ReadError{
+		File: name,
+		Line: s.line,
+		Err:  fmt.Errorf(format, args...),
+	}
+}
+
+// TSVReader reads tags from tab separated "word<TAB>POS" lines, the format
+// produced by the Stanford POS Tagger. Lines without a tab are skipped.
+type TSVReader struct {
+	lines *lineScanner
+
+	// Name is the file name used in errors. It's optional.
+	Name string
+}
+
+// NewTSVReader returns a TSVReader that reads from "r".
+func NewTSVReader(r io.Reader) *TSVReader {
+	return &TSVReader{lines: newLineScanner(r)}
+}
+
+// ReadTag returns the next tag.
+func (tr *TSVReader) ReadTag() (Tag, error) {
+	for {
+		line, err := tr.lines.next(tr.Name)
+		if err != nil {
+			return Tag{}, err
+		}
+
+		line = strings.TrimSpace(line)
+
+		i := strings.IndexByte(line, '\t')
+		if i <= 0 || i == len(line)-1 {
+			continue
+		}
+
+		return Tag{
+			Text: line[:i],
+			POS:  line[i+1:],
+		}, nil
+	}
+}
+
+// ReadTSV reads every tag with a TSVReader.
+func ReadTSV(r io.Reader) ([]Tag, error) {
+	return readAllTags(NewTSVReader(r))
+}
+
+// readAllTags reads tags until the end of the input.
+func readAllTags(tr TagReader) ([]Tag, error) {
+	var tags []Tag
+
+	for {
+		tag, err := tr.ReadTag()
+		if err == io.EOF {
+			return tags, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, tag)
+	}
+}
+
+// readTags sends the tags from "tr" to the returned channel, which is closed
+// after the last one. If the TagReader fails, the error is passed to "fail"
+// and the channel is left open, so it isn't mistaken for the end of the
+// input. It stops early if the context is cancelled.
+func readTags(ctx context.Context, tr TagReader, fail func(error)) <-chan Tag {
+	tags := make(chan Tag)
+
+	go func() {
+		for {
+			tag, err := tr.ReadTag()
+			if err == io.EOF {
+				close(tags)
+				return
+			}
+			if err != nil {
+				fail(err)
+				return
+			}
+
+			select {
+			case tags <- tag:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return tags
+}
@@ -0,0 +1,152 @@
+package randtxt
+
+import (
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pboyd/markov"
+)
+
+// failingReader returns the text, then an error instead of io.EOF, like a
+// truncated file.
+type failingReader struct {
+	r io.Reader
+}
+
+var errTruncated = errors.New("truncated")
+
+func (fr *failingReader) Read(p []byte) (int, error) {
+	n, err := fr.r.Read(p)
+	if err == io.EOF {
+		return n, errTruncated
+	}
+	return n, err
+}
+
+func TestTSVReader(t *testing.T) {
+	tr := NewTSVReader(strings.NewReader("Welcome\tUH\n\n,\t,\nno tab\nIon\tNNP\n"))
+
+	tags, err := readAllTags(tr)
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	expected := []Tag{
+		{Text: "Welcome", POS: "UH"},
+		{Text: ",", POS: ","},
+		{Text: "Ion", POS: "NNP"},
+	}
+	if !reflect.DeepEqual(tags, expected) {
+		t.Errorf("got %v, want %v", tags, expected)
+	}
+}
+
+func TestTagReaderErrors(t *testing.T) {
+	cases := []struct {
+		reader TagReader
+		err    string
+	}{
+		{
+			reader: func() TagReader {
+				tr := NewTSVReader(&failingReader{strings.NewReader("Welcome\tUH\n,\t,\n")})
+				tr.Name = "ion.tsv"
+				return tr
+			}(),
+			err: "ion.tsv:3: truncated",
+		},
+		{
+			reader: func() TagReader {
+				ir := NewInlineReader(strings.NewReader("Welcome_UH ,_,\nIon ._."), '_')
+				ir.Name = "ion.txt"
+				return ir
+			}(),
+			err: `ion.txt:2: token "Ion" isn't in the form word_POS`,
+		},
+		{
+			reader: func() TagReader {
+				cr := NewCoNLLUReader(strings.NewReader("# newpar\n1\tWelcome\n"), UPOSColumn)
+				cr.Name = "ion.conllu"
+				return cr
+			}(),
+			err: "ion.conllu:2: want 10 fields, got 2",
+		},
+		{
+			reader: NewCoNLLUReader(&failingReader{strings.NewReader("1\tWelcome\twelcome\tINTJ\tUH\t_\t0\troot\t_\t_")}, UPOSColumn),
+			err:    "line 2: truncated",
+		},
+	}
+
+	for i, c := range cases {
+		_, err := readAllTags(c.reader)
+
+		readErr, ok := err.(*ReadError)
+		if !ok {
+			t.Errorf("%d: got error %v, want a ReadError", i, err)
+			continue
+		}
+
+		if readErr.Error() != c.err {
+			t.Errorf("%d: got error %q, want %q", i, readErr.Error(), c.err)
+		}
+	}
+}
+
+func TestFeedReaders(t *testing.T) {
+	fh, err := os.Open("testfiles/ion/tagged.tsv")
+	if err != nil {
+		t.Fatalf("could not open file: %v", err)
+	}
+	defer fh.Close()
+
+	chain := markov.NewMemoryChain(0)
+	b := NewModelBuilder(chain, 2)
+	err = b.FeedReaders(
+		NewTSVReader(fh),
+		NewCoNLLUReader(strings.NewReader("1\tWelcome\twelcome\tINTJ\tUH\t_\t0\troot\t_\t_\n"), XPOSColumn),
+	)
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	md, err := ReadMetadata(chain)
+	if err != nil {
+		t.Fatalf("got error %v reading metadata", err)
+	}
+
+	if md.Tokens != 4258 {
+		t.Errorf("got %d tokens, want 4258", md.Tokens)
+	}
+}
+
+func TestFeedReadersError(t *testing.T) {
+	fh, err := os.Open("testfiles/ion/tagged.tsv")
+	if err != nil {
+		t.Fatalf("could not open file: %v", err)
+	}
+	defer fh.Close()
+
+	truncated := NewTSVReader(&failingReader{io.LimitReader(fh, 1000)})
+	truncated.Name = "tagged.tsv"
+
+	chain := markov.NewMemoryChain(0)
+	b := NewModelBuilder(chain, 2)
+	err = b.FeedReaders(truncated, NewTSVReader(strings.NewReader("Welcome\tUH\n")))
+
+	readErr, ok := err.(*ReadError)
+	if !ok {
+		t.Fatalf("got error %v, want a ReadError", err)
+	}
+
+	if readErr.File != "tagged.tsv" || readErr.Err != errTruncated {
+		t.Errorf("got error %v", err)
+	}
+
+	_, err = ReadMetadata(chain)
+	if err != ErrNoMetadata {
+		t.Errorf("got error %v reading metadata, want ErrNoMetadata", err)
+	}
+}
@@ -0,0 +1,114 @@
+package randtxt
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/pboyd/markov"
+)
+
+// Sampling controls how the next tag is chosen from the candidates in the
+// chain. The zero value samples directly from the probabilities in the chain.
+type Sampling struct {
+	// Temperature rescales the probabilities before sampling. Values
+	// below 1 favor the likeliest tags, values above 1 flatten the
+	// distribution. Zero is treated as 1.
+	Temperature float64
+
+	// TopK limits sampling to the K likeliest tags. Zero means no limit.
+	TopK int
+
+	// TopP limits sampling to the smallest set of the likeliest tags whose
+	// combined probability is at least TopP (nucleus sampling). Zero means
+	// no limit.
+	TopP float64
+
+	// Greedy always chooses the likeliest tag. The other fields are
+	// ignored when Greedy is set.
+	Greedy bool
+}
+
+// pick chooses one of the links.
+func (s Sampling) pick(links []markov.Link, r *rand.Rand) markov.Link {
+	if len(links) == 0 {
+		return markov.Link{}
+	}
+
+	if s.Greedy || s.TopK > 0 || s.TopP > 0 {
+		links = sortLinks(links)
+	}
+
+	if s.Greedy {
+		return links[0]
+	}
+
+	if s.TopK > 0 && s.TopK < len(links) {
+		links = links[:s.TopK]
+	}
+
+	weights := make([]float64, len(links))
+	for i, link := range links {
+		weights[i] = link.Probability
+	}
+
+	if s.Temperature > 0 && s.Temperature != 1 {
+		// Scale by the largest weight first so low temperatures don't
+		// underflow every weight to zero.
+		max := 0.0
+		for _, w := range weights {
+			max = math.Max(max, w)
+		}
+		for i, w := range weights {
+			if max > 0 {
+				w /= max
+			}
+			weights[i] = math.Pow(w, 1/s.Temperature)
+		}
+	}
+
+	if s.TopP > 0 && s.TopP < 1 {
+		total := sum(weights)
+		passed := 0.0
+		for i, w := range weights {
+			passed += w / total
+			if passed >= s.TopP {
+				weights = weights[:i+1]
+				break
+			}
+		}
+	}
+
+	index := r.Float64() * sum(weights)
+	var passed float64
+
+	for i, w := range weights {
+		passed += w
+		if passed > index {
+			return links[i]
+		}
+	}
+
+	// Rounding errors can leave index just past the end.
+	return links[len(weights)-1]
+}
+
+// sortLinks returns a copy of links sorted from most to least probable.
+func sortLinks(links []markov.Link) []markov.Link {
+	sorted := make([]markov.Link, len(links))
+	copy(sorted, links)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Probability > sorted[j].Probability
+	})
+
+	return sorted
+}
+
+func sum(values []float64) float64 {
+	total := 0.0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
@@ -0,0 +1,112 @@
+package randtxt
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/pboyd/markov"
+)
+
+func TestSampling(t *testing.T) {
+	links := []markov.Link{
+		{ID: 1, Probability: 0.1},
+		{ID: 2, Probability: 0.6},
+		{ID: 3, Probability: 0.3},
+	}
+
+	cases := map[string]struct {
+		sampling Sampling
+		allowed  map[int]bool
+	}{
+		"default": {
+			sampling: Sampling{},
+			allowed:  map[int]bool{1: true, 2: true, 3: true},
+		},
+		"greedy": {
+			sampling: Sampling{Greedy: true},
+			allowed:  map[int]bool{2: true},
+		},
+		"top-k": {
+			sampling: Sampling{TopK: 2},
+			allowed:  map[int]bool{2: true, 3: true},
+		},
+		"top-p": {
+			sampling: Sampling{TopP: 0.5},
+			allowed:  map[int]bool{2: true},
+		},
+		"cold": {
+			sampling: Sampling{Temperature: 0.01},
+			allowed:  map[int]bool{2: true},
+		},
+	}
+
+	r := rand.New(rand.NewSource(1))
+
+	for desc, c := range cases {
+		seen := map[int]bool{}
+		for i := 0; i < 1000; i++ {
+			link := c.sampling.pick(links, r)
+			if !c.allowed[link.ID] {
+				t.Fatalf("%s: got %d", desc, link.ID)
+			}
+			seen[link.ID] = true
+		}
+
+		if len(seen) != len(c.allowed) {
+			t.Errorf("%s: got %d different links, want %d", desc, len(seen), len(c.allowed))
+		}
+	}
+}
+
+func TestGreedyModel(t *testing.T) {
+	chain, close := testChain(t, "testfiles/ion/trigram.mkv")
+	defer close()
+
+	model, err := NewModel(chain, "", rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	model.Sampling = Sampling{Greedy: true}
+
+	for i := 0; i < 20; i++ {
+		next, err := model.NextTags()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		best := next[0]
+		for _, tp := range next[1:] {
+			if tp.Probability > best.Probability {
+				best = tp
+			}
+		}
+
+		err = model.Step()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if model.Current() != best.Tag() {
+			t.Errorf("got %v, want %v", model.Current(), best.Tag())
+		}
+	}
+}
+
+func TestSamplingColdRareLinks(t *testing.T) {
+	// Raising these to 1/0.01 underflows unless they're scaled first.
+	links := []markov.Link{
+		{ID: 1, Probability: 0.0002},
+		{ID: 2, Probability: 0.0005},
+		{ID: 3, Probability: 0.0003},
+	}
+
+	s := Sampling{Temperature: 0.01}
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 100; i++ {
+		link := s.pick(links, r)
+		if link.ID != 2 {
+			t.Fatalf("got %d, want 2", link.ID)
+		}
+	}
+}
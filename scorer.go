@@ -0,0 +1,282 @@
+package randtxt
+
+import (
+	"context"
+	"math"
+	"strings"
+
+	"github.com/pboyd/markov"
+)
+
+// Scorer measures how likely tagged text is under a model built by
+// ModelBuilder.
+type Scorer struct {
+	model    *Model
+	size     int
+	markers  bool
+	metadata *Metadata
+	vocab    int
+
+	// TagSet is used to normalize the tags before they're scored, the
+	// same way ModelBuilder does. It must match the TagSet used when the
+	// model was built. If the chain has metadata this is checked when
+	// scoring. NewScorer sets it the same way NewGenerator does.
+	TagSet TagSet
+
+	// Estimator, if set, replaces the chain's probabilities with
+	// smoothed estimates, so tags that never followed their context still
+	// get a probability. Order and Unseen are still reported from the
+	// chain.
+	Estimator Estimator
+}
+
+// NewScorer returns a scorer for the chain. Returns an error if the chain has
+// an unrecognized format.
+func NewScorer(chain markov.Chain) (*Scorer, error) {
+	size, err := inspectChain(chain)
+	if err != nil {
+		return nil, err
+	}
+
+	markers, err := hasSentenceMarkers(chain)
+	if err != nil {
+		return nil, err
+	}
+
+	md, err := ReadMetadata(chain)
+	if err == ErrNoMetadata {
+		md, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	vocab, err := chainVocabulary(chain)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Scorer{
+		model: &Model{
+			chain: chain,
+			past:  make([]string, size),
+		},
+		size:     size,
+		markers:  markers,
+		metadata: md,
+		vocab:    vocab,
+		TagSet:   chainTagSet(md),
+	}, nil
+}
+
+// chainVocabulary returns the number of distinct tags in the chain.
+func chainVocabulary(chain markov.Chain) (int, error) {
+	seen := map[string]bool{}
+	err := walkChain(context.Background(), chain, func(id int, value string) error {
+		for _, gram := range strings.Split(value, " ") {
+			seen[gram] = true
+		}
+		return nil
+	})
+	return len(seen), err
+}
+
+// Score is the result of scoring a sequence of tags.
+type Score struct {
+	// Tokens has the score for each tag, in order. The tags are as they
+	// would be stored in the chain: normalized, and with boundary tags
+	// if the chain has sentence markers.
+	Tokens []TokenScore
+
+	// LogLikelihood is the sum of the log probabilities of every scored
+	// tag with a non-zero probability.
+	LogLikelihood float64
+
+	// Count is the number of tags included in LogLikelihood.
+	Count int
+
+	// Unseen is the number of tags that never follow their context in
+	// the chain. Without an Estimator they have zero probability.
+	Unseen int
+
+	// Missed is the number of tags that aren't included in
+	// LogLikelihood, either because they have zero probability or,
+	// without an Estimator, because none of their context is in the
+	// chain.
+	Missed int
+
+	// Vocabulary is the number of distinct tags in the chain.
+	Vocabulary int
+}
+
+// Perplexity returns the perplexity of every tag. Missed tags are given a
+// probability of one over the Vocabulary, so a model can't do better by
+// scoring fewer tags. Returns +Inf if there aren't any tags.
+func (s *Score) Perplexity() float64 {
+	total := s.Count + s.Missed
+	if total == 0 || s.Vocabulary == 0 && s.Missed > 0 {
+		return math.Inf(1)
+	}
+
+	floor := 0.0
+	if s.Missed > 0 {
+		floor = -math.Log(float64(s.Vocabulary))
+	}
+
+	return math.Exp(-(s.LogLikelihood + float64(s.Missed)*floor) / float64(total))
+}
+
+// Coverage returns the fraction of the tags that are included in
+// LogLikelihood. Returns zero if there aren't any tags.
+func (s *Score) Coverage() float64 {
+	total := s.Count + s.Missed
+	if total == 0 {
+		return 0
+	}
+
+	return float64(s.Count) / float64(total)
+}
+
+// TokenScore is the score of a single tag.
+type TokenScore struct {
+	Tag Tag
+
+	// LogProbability is the natural log of the probability of the tag
+	// following its context. Without an Estimator it's -Inf if the tag
+	// is Unseen, and zero if the tag wasn't scored.
+	LogProbability float64
+
+	// Order is the number of preceding tags that LogProbability is
+	// conditioned on. It's less than the chain's ngram size when the
+	// model had to back off to a shorter context, and zero if none of
+	// the context was found in the chain (as with the first tag), in
+	// which case the tag is only scored if there's an Estimator.
+	Order int
+
+	// Unseen is set if the tag never follows any part of its context in
+	// the chain.
+	Unseen bool
+}
+
+// Score scores each tag against the tags before it.
+func (s *Scorer) Score(tags []Tag) (*Score, error) {
+	return s.ScoreContext(context.Background(), tags)
+}
+
+// ScoreContext is like Score but stops and returns the context's error if the
+// context is cancelled first.
+func (s *Scorer) ScoreContext(ctx context.Context, source []Tag) (*Score, error) {
+	err := checkTagSet(s.metadata, s.TagSet)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := s.prepare(source)
+
+	raw := make([]string, len(tags))
+	for i, tag := range tags {
+		raw[i] = tag.String()
+	}
+
+	score := &Score{
+		Tokens:     make([]TokenScore, len(tags)),
+		Vocabulary: s.vocab,
+	}
+
+	for i, tag := range tags {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		start := i - s.size
+		if start < 0 {
+			start = 0
+		}
+
+		ts, err := s.scoreToken(raw[start:i], raw[i])
+		if err != nil {
+			return nil, err
+		}
+		ts.Tag = tag
+
+		if s.Estimator != nil {
+			ts.LogProbability = math.Log(s.Estimator.Probability(tags[start:i], tag))
+		}
+
+		score.Tokens[i] = ts
+
+		if ts.Unseen {
+			score.Unseen++
+		}
+
+		scored := ts.Order > 0 || s.Estimator != nil
+		if scored && !math.IsInf(ts.LogProbability, -1) {
+			score.LogLikelihood += ts.LogProbability
+			score.Count++
+		} else {
+			score.Missed++
+		}
+	}
+
+	return score, nil
+}
+
+// prepare converts the tags to the form ModelBuilder stores them in.
+func (s *Scorer) prepare(source []Tag) []Tag {
+	prep := &tagPreparer{
+		tagSet:  s.TagSet,
+		markers: s.markers,
+	}
+
+	var tags []Tag
+	for _, tag := range source {
+		tags = append(tags, prep.add(tag)...)
+	}
+
+	return append(tags, prep.end()...)
+}
+
+// scoreToken finds the probability of "next" following the longest suffix of
+// "past" that it follows in the chain.
+func (s *Scorer) scoreToken(past []string, next string) (TokenScore, error) {
+	var ts TokenScore
+
+	for start := range past {
+		context := past[start:]
+
+		links, err := s.model.contextLinks(context)
+		if err != nil {
+			return ts, err
+		}
+
+		if len(links) == 0 {
+			continue
+		}
+
+		if ts.Order == 0 {
+			// This is the longest known context, it will be
+			// reported if "next" is never found.
+			ts.Order = len(context)
+		}
+
+		for _, link := range links {
+			value, err := s.model.chain.Get(link.ID)
+			if err != nil {
+				return ts, err
+			}
+
+			if value.(string) == next {
+				ts.Order = len(context)
+				ts.LogProbability = math.Log(link.Probability)
+				return ts, nil
+			}
+		}
+	}
+
+	if ts.Order > 0 {
+		ts.Unseen = true
+		ts.LogProbability = math.Inf(-1)
+	}
+
+	return ts, nil
+}
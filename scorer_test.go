@@ -0,0 +1,120 @@
+package randtxt
+
+import (
+	"math"
+	"testing"
+
+	"github.com/pboyd/markov"
+)
+
+func TestScorer(t *testing.T) {
+	chain, close := testChain(t, "testfiles/ion/trigram.mkv")
+	defer close()
+
+	tags := readTestFile(t, "testfiles/ion/tagged.tsv", ReadTSV)
+
+	s, err := NewScorer(chain)
+	if err != nil {
+		t.Fatalf("invalid chain: %v", err)
+	}
+
+	score, err := s.Score(tags)
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	if score.Unseen != 0 {
+		t.Errorf("got %d unseen tags in the source text, want 0", score.Unseen)
+	}
+
+	if score.Count < len(tags)/2 {
+		t.Errorf("got %d scored tags, want at least %d", score.Count, len(tags)/2)
+	}
+
+	if score.Tokens[0].Order != 0 {
+		t.Errorf("first tag was scored with order %d, want 0", score.Tokens[0].Order)
+	}
+
+	total := 0.0
+	for _, ts := range score.Tokens {
+		total += ts.LogProbability
+	}
+
+	if math.Abs(total-score.LogLikelihood) > 1e-9 {
+		t.Errorf("got log likelihood %f, want %f", score.LogLikelihood, total)
+	}
+
+	perplexity := score.Perplexity()
+	if perplexity < 1 || math.IsInf(perplexity, 0) {
+		t.Errorf("got perplexity %f, want a finite value >= 1", perplexity)
+	}
+}
+
+func TestScorerUnseen(t *testing.T) {
+	chain, close := testChain(t, "testfiles/ion/trigram.mkv")
+	defer close()
+
+	tags := readTestFile(t, "testfiles/ion/tagged.tsv", ReadTSV)[:20]
+	tags = append(tags, Tag{Text: "purple", POS: "JJ"})
+
+	s, err := NewScorer(chain)
+	if err != nil {
+		t.Fatalf("invalid chain: %v", err)
+	}
+
+	score, err := s.Score(tags)
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	if score.Unseen != 1 {
+		t.Errorf("got %d unseen tags, want 1", score.Unseen)
+	}
+
+	last := score.Tokens[len(score.Tokens)-1]
+	if !last.Unseen || !math.IsInf(last.LogProbability, -1) {
+		t.Errorf("got %+v, want an unseen tag", last)
+	}
+
+	// The unseen tag should make the text less likely, not be left out.
+	seen, err := s.Score(tags[:len(tags)-1])
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	if score.Perplexity() <= seen.Perplexity() {
+		t.Errorf("got perplexity %f with an unseen tag, want more than %f", score.Perplexity(), seen.Perplexity())
+	}
+
+	if score.Coverage() >= seen.Coverage() {
+		t.Errorf("got coverage %f with an unseen tag, want less than %f", score.Coverage(), seen.Coverage())
+	}
+}
+
+func TestScorerSentenceMarkers(t *testing.T) {
+	chain := markov.NewMemoryChain(0)
+	b := NewModelBuilder(chain, 2)
+	b.SentenceMarkers = true
+	err := b.Feed(sliceFeed(readTestFile(t, "testfiles/ion/tagged.tsv", ReadTSV)...))
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	s, err := NewScorer(chain)
+	if err != nil {
+		t.Fatalf("invalid chain: %v", err)
+	}
+
+	score, err := s.Score(readTestFile(t, "testfiles/ion/tagged.tsv", ReadTSV)[:13])
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	if score.Tokens[0].Tag != SentenceStartTag {
+		t.Errorf("got first tag %v, want %v", score.Tokens[0].Tag, SentenceStartTag)
+	}
+
+	if score.Unseen != 0 {
+		t.Errorf("got %d unseen tags, want 0", score.Unseen)
+	}
+}
@@ -0,0 +1,285 @@
+package randtxt
+
+import (
+	"sort"
+	"strings"
+)
+
+// Estimator estimates the probability of a tag following a context. Unlike
+// the probabilities stored in a chain, an Estimator can give a probability
+// to tags that never followed the context in the source text.
+//
+// Model.NextTags and Scorer use an Estimator when one is set.
+type Estimator interface {
+	// Probability returns the probability of "next" following
+	// "context". Tags in the context are oldest first.
+	Probability(context []Tag, next Tag) float64
+
+	// Vocabulary returns every tag that was counted.
+	Vocabulary() []Tag
+}
+
+// NewAddK returns an Estimator that adds "k" to the count of every tag in
+// the vocabulary before computing the probabilities (additive, or Laplace,
+// smoothing). Only the longest context is used.
+func NewAddK(counts *Counts, k float64) Estimator {
+	return &addK{
+		size:   counts.size,
+		k:      k,
+		counts: counts.ngrams,
+		totals: contextTotals(counts.ngrams),
+		vocab:  vocabulary(counts.ngrams),
+	}
+}
+
+type addK struct {
+	size   int
+	k      float64
+	counts map[string]map[string]int
+	totals map[string]int
+	vocab  []string
+}
+
+func (a *addK) Probability(context []Tag, next Tag) float64 {
+	h := joinRaw(lastTags(context, a.size))
+
+	denominator := float64(a.totals[h]) + a.k*float64(len(a.vocab))
+	if denominator == 0 {
+		return 0
+	}
+
+	return (float64(a.counts[h][next.String()]) + a.k) / denominator
+}
+
+func (a *addK) Vocabulary() []Tag {
+	return parseTags(a.vocab)
+}
+
+// NewKneserNey returns an Estimator that uses interpolated modified
+// Kneser-Ney smoothing, as described by Chen and Goodman in "An Empirical
+// Study of Smoothing Techniques for Language Modeling".
+//
+// The discounts for each context length are estimated from the counts. Tags
+// outside the vocabulary are given a small, non-zero probability.
+func NewKneserNey(counts *Counts) Estimator {
+	kn := &kneserNey{
+		vocab:  vocabulary(counts.ngrams),
+		orders: make([]knOrder, counts.size+1),
+	}
+
+	// The longest contexts use the raw counts.
+	for h, nexts := range counts.ngrams {
+		if contextLength(h) == counts.size {
+			kn.orders[counts.size].add(h, nexts, false)
+		}
+	}
+
+	// Shorter contexts use continuation counts: the number of different
+	// tags that came before the context and "next".
+	for length := counts.size - 1; length >= 0; length-- {
+		for h, nexts := range counts.ngrams {
+			if contextLength(h) == length+1 {
+				kn.orders[length].add(dropFirst(h), nexts, true)
+			}
+		}
+	}
+
+	for i := range kn.orders {
+		kn.orders[i].discount()
+	}
+
+	return kn
+}
+
+type kneserNey struct {
+	vocab  []string
+	orders []knOrder
+}
+
+// knOrder holds the counts and discounts for one context length.
+type knOrder struct {
+	counts map[string]map[string]int
+	totals map[string]int
+
+	// d holds the discounts for counts of 1, 2 and 3 or more. d[0] is
+	// unused.
+	d [4]float64
+
+	// gammas is the total discount taken from each context, which is
+	// given to the shorter context.
+	gammas map[string]float64
+}
+
+// knDefaultDiscounts are used when there aren't enough counts to estimate
+// them.
+var knDefaultDiscounts = [4]float64{0, 0.5, 1, 1.5}
+
+// add adds the counts of the tags following "h". If "continuation" is set
+// each tag is counted once.
+func (o *knOrder) add(h string, nexts map[string]int, continuation bool) {
+	if o.counts == nil {
+		o.counts = map[string]map[string]int{}
+		o.totals = map[string]int{}
+	}
+
+	counts := o.counts[h]
+	if counts == nil {
+		counts = map[string]int{}
+		o.counts[h] = counts
+	}
+
+	for next, n := range nexts {
+		if continuation {
+			n = 1
+		}
+
+		counts[next] += n
+		o.totals[h] += n
+	}
+}
+
+// discount estimates the discounts from the counts of counts and finds the
+// gamma for each context.
+func (o *knOrder) discount() {
+	var n [5]float64
+	for _, nexts := range o.counts {
+		for _, c := range nexts {
+			if c <= 4 {
+				n[c]++
+			}
+		}
+	}
+
+	o.d = knDefaultDiscounts
+	if n[1] > 0 && n[2] > 0 && n[3] > 0 && n[4] > 0 {
+		y := n[1] / (n[1] + 2*n[2])
+		d := [4]float64{
+			0,
+			1 - 2*y*n[2]/n[1],
+			2 - 3*y*n[3]/n[2],
+			3 - 4*y*n[4]/n[3],
+		}
+
+		if d[1] > 0 && d[2] > 0 && d[3] > 0 {
+			o.d = d
+		}
+	}
+
+	o.gammas = make(map[string]float64, len(o.counts))
+	for h, nexts := range o.counts {
+		for _, c := range nexts {
+			o.gammas[h] += o.discountFor(c)
+		}
+	}
+}
+
+// discountFor returns the discount for a count of "c". It's never more than
+// "c".
+func (o *knOrder) discountFor(c int) float64 {
+	switch {
+	case c <= 0:
+		return 0
+	case c >= 3:
+		return o.d[3]
+	case o.d[c] > float64(c):
+		return float64(c)
+	default:
+		return o.d[c]
+	}
+}
+
+func (kn *kneserNey) Probability(context []Tag, next Tag) float64 {
+	context = lastTags(context, len(kn.orders)-1)
+
+	raw := make([]string, len(context))
+	for i, tag := range context {
+		raw[i] = tag.String()
+	}
+
+	return kn.probability(raw, next.String())
+}
+
+// probability returns the probability of "next" following "past",
+// interpolated with the probability of it following every shorter suffix of
+// "past".
+func (kn *kneserNey) probability(past []string, next string) float64 {
+	if len(kn.vocab) == 0 {
+		return 0
+	}
+
+	// The shortest context is interpolated with the uniform
+	// distribution.
+	p := 1 / float64(len(kn.vocab))
+
+	for start := len(past); start >= 0; start-- {
+		order := &kn.orders[len(past)-start]
+		h := strings.Join(past[start:], " ")
+
+		total := order.totals[h]
+		if total == 0 {
+			continue
+		}
+
+		c := order.counts[h][next]
+		p = (float64(c)-order.discountFor(c))/float64(total) + order.gammas[h]/float64(total)*p
+	}
+
+	return p
+}
+
+func (kn *kneserNey) Vocabulary() []Tag {
+	return parseTags(kn.vocab)
+}
+
+// contextTotals returns the number of tags counted after each context.
+func contextTotals(ngrams map[string]map[string]int) map[string]int {
+	totals := make(map[string]int, len(ngrams))
+	for h, nexts := range ngrams {
+		for _, n := range nexts {
+			totals[h] += n
+		}
+	}
+	return totals
+}
+
+// vocabulary returns every raw tag in the unigram counts, sorted.
+func vocabulary(ngrams map[string]map[string]int) []string {
+	vocab := make([]string, 0, len(ngrams[""]))
+	for next := range ngrams[""] {
+		vocab = append(vocab, next)
+	}
+	sort.Strings(vocab)
+	return vocab
+}
+
+func contextLength(h string) int {
+	if h == "" {
+		return 0
+	}
+	return strings.Count(h, " ") + 1
+}
+
+// dropFirst removes the first tag from a context.
+func dropFirst(h string) string {
+	i := strings.IndexByte(h, ' ')
+	if i < 0 {
+		return ""
+	}
+	return h[i+1:]
+}
+
+// lastTags returns at most the last "n" tags.
+func lastTags(tags []Tag, n int) []Tag {
+	if len(tags) > n {
+		return tags[len(tags)-n:]
+	}
+	return tags
+}
+
+func parseTags(raw []string) []Tag {
+	tags := make([]Tag, len(raw))
+	for i, r := range raw {
+		tags[i] = parseTag(r)
+	}
+	return tags
+}
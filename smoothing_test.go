@@ -0,0 +1,127 @@
+package randtxt
+
+import (
+	"math"
+	"testing"
+
+	"github.com/pboyd/markov"
+)
+
+func TestEstimators(t *testing.T) {
+	_, counts := countedChain(t, 2)
+
+	context := []Tag{{Text: "the", POS: "DT"}, {Text: "poet", POS: "NN"}}
+	unseen := []Tag{{Text: "purple", POS: "JJ"}, {Text: "lyre", POS: "NN"}}
+
+	estimators := map[string]Estimator{
+		"add-k":      NewAddK(counts, 0.1),
+		"kneser-ney": NewKneserNey(counts),
+	}
+
+	for name, e := range estimators {
+		for _, c := range [][]Tag{context, unseen, nil} {
+			sum := 0.0
+			for _, tag := range e.Vocabulary() {
+				p := e.Probability(c, tag)
+				if p <= 0 {
+					t.Errorf("%s: got probability %f for %v after %v, want > 0", name, p, tag, c)
+				}
+				sum += p
+			}
+
+			if math.Abs(sum-1) > 1e-9 {
+				t.Errorf("%s: probabilities after %v add up to %f, want 1", name, c, sum)
+			}
+		}
+
+		p := e.Probability(context, Tag{Text: "purple", POS: "JJ"})
+		if p <= 0 {
+			t.Errorf("%s: got probability %f for an unknown tag, want > 0", name, p)
+		}
+	}
+}
+
+func TestAddKZero(t *testing.T) {
+	chain, counts := countedChain(t, 2)
+
+	model, err := NewModel(chain, "the/DT poet/NN", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	raw, err := model.NextTags()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Without anything added the estimates are the same as the chain's.
+	model.Estimator = NewAddK(counts, 0)
+	estimated, err := model.NextTags()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(raw) != len(estimated) {
+		t.Fatalf("got %d tags, want %d", len(estimated), len(raw))
+	}
+
+	want := map[Tag]float64{}
+	for _, tp := range raw {
+		want[tp.Tag()] = tp.Probability
+	}
+
+	for _, tp := range estimated {
+		if math.Abs(want[tp.Tag()]-tp.Probability) > 1e-9 {
+			t.Errorf("%v: got %f, want %f", tp.Tag(), tp.Probability, want[tp.Tag()])
+		}
+	}
+}
+
+func TestSmoothedScore(t *testing.T) {
+	chain, counts := countedChain(t, 3)
+
+	tags := readTestFile(t, "testfiles/ion/tagged.tsv", ReadTSV)[:20]
+	tags = append(tags, Tag{Text: "purple", POS: "JJ"})
+
+	s, err := NewScorer(chain)
+	if err != nil {
+		t.Fatalf("invalid chain: %v", err)
+	}
+	s.Estimator = NewKneserNey(counts)
+
+	score, err := s.Score(tags)
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	if score.Unseen != 1 {
+		t.Errorf("got %d unseen tags, want 1", score.Unseen)
+	}
+
+	for i, ts := range score.Tokens {
+		if ts.LogProbability >= 0 || math.IsInf(ts.LogProbability, 0) {
+			t.Errorf("tag %d: got log probability %f", i, ts.LogProbability)
+		}
+	}
+
+	if score.Count != len(score.Tokens) {
+		t.Errorf("got %d scored tags, want %d", score.Count, len(score.Tokens))
+	}
+}
+
+// countedChain builds a chain from the test corpus and returns it with its
+// counts.
+func countedChain(t *testing.T, size int) (markov.Chain, *Counts) {
+	t.Helper()
+
+	chain := markov.NewMemoryChain(0)
+	b := NewModelBuilder(chain, size)
+	b.Counts = NewCounts()
+
+	err := b.Feed(sliceFeed(readTestFile(t, "testfiles/ion/tagged.tsv", ReadTSV)...))
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	return chain, b.Counts
+}
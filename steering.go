@@ -0,0 +1,143 @@
+package randtxt
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pboyd/markov"
+)
+
+const (
+	// defaultSteeringDepth is the number of steps to look ahead when
+	// Steering.Depth is zero.
+	defaultSteeringDepth = 3
+
+	// steeringCutoff is the probability below which a path isn't worth
+	// following any further.
+	steeringCutoff = 0.001
+)
+
+// Steering biases generation toward keywords. The zero value doesn't steer.
+//
+// Each candidate for the next tag is weighted by the probability that it leads
+// to one of the keywords within a few steps. Candidates that can't reach a
+// keyword keep their original weight, and if none of the candidates can reach
+// one the chain's probabilities are used unchanged.
+type Steering struct {
+	// Keywords are the words to steer toward. They match tags with the
+	// same text, regardless of case or part of speech.
+	Keywords []string
+
+	// Strength is how strongly to steer. A candidate that's certain to
+	// lead to a keyword is weighted 1+Strength times higher than its
+	// original probability. Zero disables steering.
+	Strength float64
+
+	// Depth is the number of steps, including the next one, to look
+	// ahead for a keyword. Zero means 3.
+	Depth int
+}
+
+func (s Steering) enabled() bool {
+	return s.Strength > 0 && len(s.Keywords) > 0
+}
+
+func (s Steering) keywordSet() map[string]bool {
+	set := make(map[string]bool, len(s.Keywords))
+	for _, kw := range s.Keywords {
+		set[strings.ToLower(kw)] = true
+	}
+	return set
+}
+
+// steer reweights the links according to the model's Steering.
+func (m *Model) steer(ctx context.Context, links []markov.Link) ([]markov.Link, error) {
+	if !m.Steering.enabled() {
+		return links, nil
+	}
+
+	keywords := m.Steering.keywordSet()
+
+	depth := m.Steering.Depth
+	if depth <= 0 {
+		depth = defaultSteeringDepth
+	}
+
+	steered := make([]markov.Link, len(links))
+	reachable := false
+
+	for i, link := range links {
+		raw, err := m.chain.Get(link.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		reach, err := m.reach(ctx, shiftPast(m.past, raw.(string)), keywords, depth-1, link.Probability)
+		if err != nil {
+			return nil, err
+		}
+
+		if reach > 0 {
+			reachable = true
+		}
+
+		steered[i] = markov.Link{
+			ID:          link.ID,
+			Probability: link.Probability * (1 + m.Steering.Strength*reach),
+		}
+	}
+
+	if !reachable {
+		return links, nil
+	}
+
+	return steered, nil
+}
+
+// reach returns the probability that a keyword is the last tag in "past" or
+// one of the next "depth" tags. "pathProb" is the probability of the path that
+// led to "past", and paths less likely than steeringCutoff are abandoned.
+func (m *Model) reach(ctx context.Context, past []string, keywords map[string]bool, depth int, pathProb float64) (float64, error) {
+	if keywords[strings.ToLower(parseTag(past[len(past)-1]).Text)] {
+		return 1, nil
+	}
+
+	if depth <= 0 || pathProb < steeringCutoff {
+		return 0, nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	links, err := m.backoffLinks(past)
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0.0
+	for _, link := range links {
+		raw, err := m.chain.Get(link.ID)
+		if err != nil {
+			return 0, err
+		}
+
+		reach, err := m.reach(ctx, shiftPast(past, raw.(string)), keywords, depth-1, pathProb*link.Probability)
+		if err != nil {
+			return 0, err
+		}
+
+		total += link.Probability * reach
+	}
+
+	return total, nil
+}
+
+// shiftPast returns a copy of "past" with "next" added to the end and the
+// first tag removed.
+func shiftPast(past []string, next string) []string {
+	shifted := make([]string, len(past))
+	copy(shifted, past[1:])
+	shifted[len(shifted)-1] = next
+	return shifted
+}
@@ -0,0 +1,67 @@
+package randtxt
+
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSteering(t *testing.T) {
+	chain, close := testChain(t, "testfiles/ion/trigram.mkv")
+	defer close()
+
+	count := func(steering Steering) int {
+		model, err := NewModel(chain, "", rand.New(rand.NewSource(1)))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		model.Steering = steering
+
+		n := 0
+		for i := 0; i < 2000; i++ {
+			err := model.Step()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if strings.ToLower(model.Current().Text) == "poet" {
+				n++
+			}
+		}
+		return n
+	}
+
+	unsteered := count(Steering{})
+	steered := count(Steering{Keywords: []string{"Poet"}, Strength: 20})
+
+	if steered <= unsteered {
+		t.Errorf("got %d keywords with steering, want more than %d", steered, unsteered)
+	}
+}
+
+func TestSteeringUnreachable(t *testing.T) {
+	chain, close := testChain(t, "testfiles/ion/trigram.mkv")
+	defer close()
+
+	model, err := NewModel(chain, "", rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	model.Steering = Steering{Keywords: []string{"purple"}, Strength: 20}
+
+	links, err := model.nextLinks(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	steered, err := model.steer(context.Background(), links)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(steered, links) {
+		t.Errorf("links changed when no keyword is reachable")
+	}
+}
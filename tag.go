@@ -1,9 +1,6 @@
 package randtxt
 
-import (
-	"fmt"
-	"strings"
-)
+import "strings"
 
 // Tag represents a single tagged word.
 type Tag struct {
@@ -13,27 +10,141 @@ type Tag struct {
 	POS string
 }
 
+// Boundary tags are written by ModelBuilder when SentenceMarkers is set. They
+// are not words and TagSet.Join renders them as nothing.
+var (
+	// SentenceStartTag marks the start of a sentence.
+	SentenceStartTag = Tag{Text: "<s>", POS: "<s>"}
+
+	// SentenceEndTag marks the end of a sentence.
+	SentenceEndTag = Tag{Text: "</s>", POS: "</s>"}
+
+	// ParagraphBreakTag marks the end of a paragraph. ModelBuilder only
+	// writes it if the source sends it.
+	ParagraphBreakTag = Tag{Text: "<p>", POS: "<p>"}
+)
+
+// IsBoundary tests if the tag is one of the boundary tags.
+func (t Tag) IsBoundary() bool {
+	return t == SentenceStartTag || t == SentenceEndTag || t == ParagraphBreakTag
+}
+
 // IsZero tests if the tag is the empty zero value.
 func (t Tag) IsZero() bool {
 	return t.Text == "" && t.POS == ""
 }
 
-// String returns the tag in "Text/POS" form.
+// String returns the tag in "Text/POS" form, the form it's stored in a chain.
+// Boundary tags are returned as their text alone.
+//
+// Characters in the text and POS that would make the tag ambiguous are
+// percent-encoded, as in a URL: "%", "/", spaces and control characters. So
+// "and/or" tagged CC is "and%2For/CC". A "<" or "#" at the start of the text
+// is encoded too, so no word can be mistaken for a boundary tag or the
+// chain's metadata. Other text, including any Unicode, is unchanged.
 func (t Tag) String() string {
 	if t.IsZero() {
 		return ""
 	}
-	return fmt.Sprintf("%s/%s", t.Text, t.POS)
+	if t.IsBoundary() {
+		return t.Text
+	}
+	return escapeTag(t.Text, true) + "/" + escapeTag(t.POS, false)
 }
 
+// parseTag reverses Tag.String.
+//
+// Chains written before tags were escaped are mostly read correctly too: the
+// POS is split from the last "/", and a "%" that doesn't begin an escape is
+// kept. Use MigrateChain to convert them.
 func parseTag(gram string) Tag {
-	split := strings.Split(gram, "/")
-	if len(split) < 2 {
+	switch gram {
+	case SentenceStartTag.Text:
+		return SentenceStartTag
+	case SentenceEndTag.Text:
+		return SentenceEndTag
+	case ParagraphBreakTag.Text:
+		return ParagraphBreakTag
+	}
+
+	i := strings.LastIndexByte(gram, '/')
+	if i < 0 {
 		return Tag{}
 	}
 
 	return Tag{
-		Text: split[0],
-		POS:  split[1],
+		Text: unescapeTag(gram[:i]),
+		POS:  unescapeTag(gram[i+1:]),
+	}
+}
+
+const upperHex = "0123456789ABCDEF"
+
+// needsEscape tests if the byte at "i" must be percent-encoded in a tag.
+// "text" is set for the tag's text, which can't start with "<" or "#".
+func needsEscape(s string, i int, text bool) bool {
+	c := s[i]
+	if text && i == 0 && (c == '<' || c == '#') {
+		return true
+	}
+	return c <= ' ' || c == 0x7f || c == '%' || c == '/'
+}
+
+func escapeTag(s string, text bool) string {
+	n := 0
+	for i := 0; i < len(s); i++ {
+		if needsEscape(s, i, text) {
+			n++
+		}
+	}
+
+	if n == 0 {
+		return s
+	}
+
+	buf := make([]byte, 0, len(s)+2*n)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if needsEscape(s, i, text) {
+			buf = append(buf, '%', upperHex[c>>4], upperHex[c&15])
+		} else {
+			buf = append(buf, c)
+		}
+	}
+
+	return string(buf)
+}
+
+func unescapeTag(s string) string {
+	if strings.IndexByte(s, '%') < 0 {
+		return s
+	}
+
+	buf := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]) {
+			buf = append(buf, unhex(s[i+1])<<4|unhex(s[i+2]))
+			i += 2
+			continue
+		}
+
+		buf = append(buf, s[i])
+	}
+
+	return string(buf)
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
 	}
 }
@@ -2,7 +2,10 @@ package randtxt
 
 import (
 	"bytes"
+	"fmt"
+	"sort"
 	"strings"
+	"sync"
 	"unicode"
 )
 
@@ -11,7 +14,8 @@ type TagSet interface {
 	// Join returns the text from "tag" prepended with the separator that
 	// should be between "prev" and "tag".
 	//
-	// "prev" is the zero tag at the beginning of the text.
+	// "prev" is the zero tag at the beginning of the text. Boundary tags
+	// (see Tag.IsBoundary) are joined as a blank string.
 	Join(tag, prev Tag) string
 
 	// Normalize converts "tag" to a consistent form. If the returned tag
@@ -19,6 +23,85 @@ type TagSet interface {
 	Normalize(tag, prev Tag) Tag
 }
 
+// EndsSentence tests if "tag" is the last word of a sentence. If the TagSet
+// has an EndsSentence method that's used, otherwise it's the Penn Treebank
+// rule: the tag's POS is ".".
+func EndsSentence(ts TagSet, tag Tag) bool {
+	if ender, ok := ts.(interface {
+		EndsSentence(tag Tag) bool
+	}); ok {
+		return ender.EndsSentence(tag)
+	}
+
+	return tag.POS == "."
+}
+
+var (
+	tagSetsMu sync.RWMutex
+	tagSets   = map[string]TagSet{}
+)
+
+func init() {
+	RegisterTagSet(PennTreebankTagSet.Name(), PennTreebankTagSet)
+	RegisterTagSet(UniversalTagSet.Name(), UniversalTagSet)
+}
+
+// RegisterTagSet makes a TagSet available by name, for LookupTagSet. The name
+// should be the same one TagSetName returns, so generators and scorers can
+// find the TagSet a chain was built with.
+//
+// It panics if the TagSet is nil or the name is already registered.
+func RegisterTagSet(name string, ts TagSet) {
+	tagSetsMu.Lock()
+	defer tagSetsMu.Unlock()
+
+	if ts == nil {
+		panic("randtxt: RegisterTagSet TagSet is nil")
+	}
+
+	if _, dup := tagSets[name]; dup {
+		panic(fmt.Sprintf("randtxt: RegisterTagSet called twice for %q", name))
+	}
+
+	tagSets[name] = ts
+}
+
+// LookupTagSet returns the TagSet registered with "name". The second return
+// value is false if there isn't one.
+func LookupTagSet(name string) (TagSet, bool) {
+	tagSetsMu.RLock()
+	defer tagSetsMu.RUnlock()
+
+	ts, ok := tagSets[name]
+	return ts, ok
+}
+
+// TagSets returns the names of the registered TagSets, sorted.
+func TagSets() []string {
+	tagSetsMu.RLock()
+	defer tagSetsMu.RUnlock()
+
+	names := make([]string, 0, len(tagSets))
+	for name := range tagSets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// chainTagSet returns the registered TagSet named in the chain's metadata, or
+// PennTreebankTagSet if the chain has no metadata or the TagSet isn't
+// registered.
+func chainTagSet(md *Metadata) TagSet {
+	if md != nil {
+		if ts, ok := LookupTagSet(md.TagSet); ok {
+			return ts
+		}
+	}
+
+	return PennTreebankTagSet
+}
+
 // PennTreebankTagSet is a TagSet for the English Penn Treebank tagset, as used
 // by the Stanford POS tagger.
 //
@@ -30,7 +113,15 @@ var PennTreebankTagSet = pennTreebankTagSet{}
 
 type pennTreebankTagSet struct{}
 
+func (pt pennTreebankTagSet) Name() string {
+	return "penn"
+}
+
 func (pt pennTreebankTagSet) Join(this, prev Tag) string {
+	if this.IsBoundary() {
+		return ""
+	}
+
 	buf := bytes.NewBuffer(make([]byte, 0, len(this.Text)+1))
 
 	needSpace := false
@@ -56,7 +147,7 @@ func (pt pennTreebankTagSet) Join(this, prev Tag) string {
 	word := this.Text
 
 	switch prev.POS {
-	case "", ".", ":":
+	case "", ".", ":", SentenceStartTag.POS:
 		if prev.Text != ";" {
 			word = titleCase(word)
 		}
@@ -95,3 +186,135 @@ func (pt pennTreebankTagSet) Normalize(tag, prev Tag) Tag {
 
 	return tag
 }
+
+func (pt pennTreebankTagSet) EndsSentence(tag Tag) bool {
+	return tag.POS == "."
+}
+
+// UniversalTagSet is a TagSet for the 17 Universal POS tags (UPOS) of the
+// Universal Dependencies project, as used by taggers like spaCy, Stanza and
+// UDPipe. It joins English text the same way as PennTreebankTagSet.
+//
+// More details:
+//
+// https://universaldependencies.org/u/pos/
+var UniversalTagSet = universalTagSet{}
+
+type universalTagSet struct{}
+
+func (ut universalTagSet) Name() string {
+	return "universal"
+}
+
+func (ut universalTagSet) Join(this, prev Tag) string {
+	if this.IsBoundary() {
+		return ""
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, len(this.Text)+1))
+
+	needSpace := true
+
+	switch this.POS {
+	case "PUNCT":
+		needSpace = false
+	case "SYM":
+		needSpace = this.Text != "%"
+	case "PART", "AUX", "VERB":
+		// Contractions, like "n't", "'s" and "'ll".
+		if this.Text == "n't" || strings.HasPrefix(this.Text, "'") {
+			needSpace = false
+		}
+	}
+
+	// Nothing goes between a symbol and the number after it, as in "$5".
+	if prev.POS == "SYM" && ut.keepSymbol(prev.Text) && prev.Text != "%" {
+		needSpace = false
+	}
+
+	if !prev.IsZero() && needSpace {
+		buf.WriteString(" ")
+	}
+
+	word := this.Text
+
+	if prev.IsZero() || prev == SentenceStartTag || ut.startsClause(prev) {
+		word = titleCase(word)
+	}
+
+	buf.WriteString(word)
+	return buf.String()
+}
+
+// startsClause tests if the word after "tag" should be capitalized.
+func (ut universalTagSet) startsClause(tag Tag) bool {
+	if tag.POS != "PUNCT" {
+		return false
+	}
+
+	switch tag.Text {
+	case ".", "!", "?", ":", "--", "...":
+		return true
+	}
+
+	return false
+}
+
+// keepSymbol tests if a SYM tag with the given text belongs in the text.
+// Currency symbols, "#" and "%" are kept, like the Penn Treebank keeps "$"
+// and "#". Anything else a tagger calls a symbol is dropped.
+func (ut universalTagSet) keepSymbol(text string) bool {
+	if text == "#" || text == "%" {
+		return true
+	}
+
+	for _, r := range text {
+		if !unicode.Is(unicode.Sc, r) {
+			return false
+		}
+	}
+
+	return text != ""
+}
+
+func (ut universalTagSet) Normalize(tag, prev Tag) Tag {
+	switch tag.POS {
+	case "SYM":
+		if !ut.keepSymbol(tag.Text) {
+			return Tag{}
+		}
+	case "PUNCT":
+		switch tag.Text {
+		case "(", ")", "[", "]", "{", "}", "\"", "`", "``", "'", "''", "“", "”", "‘", "’":
+			return Tag{}
+		}
+	}
+
+	// Lower case words that begin sentences, unless it's a proper noun.
+	if prev.IsZero() || ut.EndsSentence(prev) {
+		if tag.Text != "I" && tag.POS != "PROPN" {
+			tag.Text = strings.ToLower(tag.Text)
+		}
+	}
+
+	switch tag.POS {
+	case "PART", "AUX", "VERB":
+	default:
+		tag.Text = strings.TrimLeft(tag.Text, "'")
+	}
+
+	return tag
+}
+
+func (ut universalTagSet) EndsSentence(tag Tag) bool {
+	if tag.POS != "PUNCT" {
+		return false
+	}
+
+	switch tag.Text {
+	case ".", "!", "?":
+		return true
+	}
+
+	return false
+}
@@ -1,6 +1,16 @@
 package randtxt
 
-import "testing"
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/pboyd/markov"
+)
 
 func TestPennTreebankJoin(t *testing.T) {
 	cases := []struct {
@@ -32,6 +42,16 @@ func TestPennTreebankJoin(t *testing.T) {
 			prev:     "he/PP",
 			expected: "'s",
 		},
+		{
+			tag:      "</s>",
+			prev:     "./.",
+			expected: "",
+		},
+		{
+			tag:      "he/PP",
+			prev:     "<s>",
+			expected: " He",
+		},
 	}
 
 	for i, c := range cases {
@@ -92,3 +112,369 @@ func TestPennTreebankNormalize(t *testing.T) {
 		}
 	}
 }
+
+func TestUniversalJoin(t *testing.T) {
+	cases := []struct {
+		tag, prev string
+		expected  string
+	}{
+		{
+			tag:      "Paul/PROPN",
+			prev:     "John/PROPN",
+			expected: " Paul",
+		},
+		{
+			tag:      "paul/PROPN",
+			prev:     "",
+			expected: "Paul",
+		},
+		{
+			tag:      "./PUNCT",
+			prev:     "ran/VERB",
+			expected: ".",
+		},
+		{
+			tag:      "he/PRON",
+			prev:     "?/PUNCT",
+			expected: " He",
+		},
+		{
+			tag:      "he/PRON",
+			prev:     ",/PUNCT",
+			expected: " he",
+		},
+		{
+			tag:      "$/SYM",
+			prev:     "about/ADV",
+			expected: " $",
+		},
+		{
+			tag:      "40/NUM",
+			prev:     "$/SYM",
+			expected: "40",
+		},
+		{
+			tag:      "%25/SYM",
+			prev:     "5/NUM",
+			expected: "%",
+		},
+		{
+			tag:      "n't/PART",
+			prev:     "do/AUX",
+			expected: "n't",
+		},
+		{
+			tag:      "'s/PART",
+			prev:     "poet/NOUN",
+			expected: "'s",
+		},
+		{
+			tag:      "'ll/AUX",
+			prev:     "you/PRON",
+			expected: "'ll",
+		},
+		{
+			tag:      "he/PRON",
+			prev:     "<s>/<s>",
+			expected: " He",
+		},
+		{
+			tag:      "</s>",
+			prev:     "./PUNCT",
+			expected: "",
+		},
+	}
+
+	for i, c := range cases {
+		tag := parseTag(c.tag)
+		prev := parseTag(c.prev)
+
+		actual := UniversalTagSet.Join(tag, prev)
+		if actual != c.expected {
+			t.Errorf("%d: got %q, want %q", i, actual, c.expected)
+		}
+	}
+}
+
+func TestUniversalNormalize(t *testing.T) {
+	cases := []struct {
+		tag, prev string
+		expected  string
+	}{
+		{
+			tag:      "Was/AUX",
+			prev:     "",
+			expected: "was/AUX",
+		},
+		{
+			tag:      "Was/AUX",
+			prev:     "!/PUNCT",
+			expected: "was/AUX",
+		},
+		{
+			tag:      "Was/AUX",
+			prev:     ",/PUNCT",
+			expected: "Was/AUX",
+		},
+		{
+			tag:      "Ringo/PROPN",
+			prev:     "./PUNCT",
+			expected: "Ringo/PROPN",
+		},
+		{
+			tag:      "(/PUNCT",
+			prev:     "",
+			expected: "",
+		},
+		{
+			tag:      "*/SYM",
+			prev:     "",
+			expected: "",
+		},
+		{
+			tag:      "$/SYM",
+			prev:     "about/ADV",
+			expected: "$/SYM",
+		},
+		{
+			tag:      "%25/SYM",
+			prev:     "5/NUM",
+			expected: "%25/SYM",
+		},
+		{
+			tag:      "'of/ADP",
+			prev:     "",
+			expected: "of/ADP",
+		},
+		{
+			tag:      "'s/PART",
+			prev:     "she/PRON",
+			expected: "'s/PART",
+		},
+	}
+
+	for i, c := range cases {
+		tag := parseTag(c.tag)
+		prev := parseTag(c.prev)
+
+		actual := UniversalTagSet.Normalize(tag, prev).String()
+
+		if actual != c.expected {
+			t.Errorf("%d: got %q, want %q", i, actual, c.expected)
+		}
+	}
+}
+
+// joinCorpus normalizes and joins every tag in the file.
+func joinCorpus(t *testing.T, path string, ts TagSet) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	var prev Tag
+	for _, tag := range readTestFile(t, path, ReadTSV) {
+		tag = ts.Normalize(tag, prev)
+		if tag.Text == "" {
+			continue
+		}
+
+		buf.WriteString(ts.Join(tag, prev))
+		prev = tag
+	}
+
+	return buf.String()
+}
+
+func TestUniversalCorpus(t *testing.T) {
+	penn := joinCorpus(t, "testfiles/ion/tagged.tsv", PennTreebankTagSet)
+	universal := joinCorpus(t, "testfiles/ion/universal.tsv", UniversalTagSet)
+
+	if universal != penn {
+		pennWords := strings.Split(penn, " ")
+		universalWords := strings.Split(universal, " ")
+		for i := range universalWords {
+			if i >= len(pennWords) || universalWords[i] != pennWords[i] {
+				t.Fatalf("universal text differs from penn text at word %d: %q", i, universalWords[i])
+			}
+		}
+		t.Fatalf("universal text is shorter than penn text")
+	}
+
+	chain := markov.NewMemoryChain(0)
+	b := NewModelBuilder(chain, 2)
+	b.TagSet = UniversalTagSet
+	b.SentenceMarkers = true
+	err := b.Feed(sliceFeed(readTestFile(t, "testfiles/ion/universal.tsv", ReadTSV)...))
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	g, err := NewGenerator(chain, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("invalid chain: %v", err)
+	}
+
+	if g.TagSet != UniversalTagSet {
+		t.Fatalf("got TagSet %v, want UniversalTagSet", g.TagSet)
+	}
+
+	for i := 0; i < 10; i++ {
+		text, err := g.Paragraph(1, 3)
+		if err != nil {
+			t.Fatalf("got error %v, want nil", err)
+		}
+		checkParagraph(t, text, 1, 3)
+	}
+}
+
+type namedTagSet struct {
+	TagSet
+	name string
+}
+
+func (ts namedTagSet) Name() string {
+	return ts.name
+}
+
+// TestUniversalTreebank joins text tagged with UPOS by hand and checks it
+// against the original text.
+func TestUniversalTreebank(t *testing.T) {
+	const path = "testfiles/reviews/tagged.conllu"
+
+	tags := readTestFile(t, path, func(r io.Reader) ([]Tag, error) {
+		return ReadCoNLLU(r, UPOSColumn)
+	})
+
+	var buf bytes.Buffer
+	var prev Tag
+	for _, tag := range tags {
+		if tag.IsBoundary() {
+			continue
+		}
+
+		tag = UniversalTagSet.Normalize(tag, prev)
+		if tag.Text == "" {
+			continue
+		}
+
+		buf.WriteString(UniversalTagSet.Join(tag, prev))
+		prev = tag
+	}
+
+	fh, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("could not open %q: %v", path, err)
+	}
+	defer fh.Close()
+
+	// Brackets and quotes are dropped by Normalize.
+	dropped := strings.NewReplacer("(", "", ")", "", "\"", "")
+
+	var sentences []string
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		text := strings.TrimPrefix(scanner.Text(), "# text = ")
+		if text != scanner.Text() {
+			sentences = append(sentences, strings.Join(strings.Fields(dropped.Replace(text)), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("could not read %q: %v", path, err)
+	}
+
+	expected := strings.Join(sentences, " ")
+	if buf.String() != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), expected)
+	}
+}
+
+// unregisterTagSet removes a TagSet registered by a test, so the test can run
+// more than once.
+func unregisterTagSet(name string) {
+	tagSetsMu.Lock()
+	defer tagSetsMu.Unlock()
+
+	delete(tagSets, name)
+}
+
+func TestTagSetRegistry(t *testing.T) {
+	ts, ok := LookupTagSet("penn")
+	if !ok || ts != PennTreebankTagSet {
+		t.Fatalf("got %v, %v for penn, want PennTreebankTagSet", ts, ok)
+	}
+
+	if _, ok := LookupTagSet("nonexistent"); ok {
+		t.Errorf("got a TagSet for an unregistered name")
+	}
+
+	custom := namedTagSet{TagSet: PennTreebankTagSet, name: "test-registry"}
+	RegisterTagSet(custom.name, custom)
+	defer unregisterTagSet(custom.name)
+
+	found := false
+	for _, name := range TagSets() {
+		found = found || name == custom.name
+	}
+	if !found {
+		t.Errorf("TagSets() = %v, missing %q", TagSets(), custom.name)
+	}
+
+	chain := markov.NewMemoryChain(0)
+	b := NewModelBuilder(chain, 2)
+	b.TagSet = custom
+	err := b.Feed(sliceFeed(readTestFile(t, "testfiles/ion/tagged.tsv", ReadTSV)...))
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	g, err := NewGenerator(chain, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("invalid chain: %v", err)
+	}
+
+	if g.TagSet != custom {
+		t.Errorf("got TagSet %v, want the registered %q", g.TagSet, custom.name)
+	}
+
+	_, err = g.Paragraph(1, 2)
+	if err != nil {
+		t.Errorf("got error %v, want nil", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("registering a name twice didn't panic")
+		}
+	}()
+	RegisterTagSet(custom.name, custom)
+}
+
+// joinOnlyTagSet is a TagSet without an EndsSentence method.
+type joinOnlyTagSet struct{}
+
+func (joinOnlyTagSet) Join(tag, prev Tag) string {
+	return PennTreebankTagSet.Join(tag, prev)
+}
+
+func (joinOnlyTagSet) Normalize(tag, prev Tag) Tag {
+	return PennTreebankTagSet.Normalize(tag, prev)
+}
+
+func TestEndsSentence(t *testing.T) {
+	cases := []struct {
+		ts   TagSet
+		tag  Tag
+		want bool
+	}{
+		{PennTreebankTagSet, Tag{Text: ".", POS: "."}, true},
+		{PennTreebankTagSet, Tag{Text: ",", POS: ","}, false},
+		{joinOnlyTagSet{}, Tag{Text: "?", POS: "."}, true},
+		{joinOnlyTagSet{}, Tag{Text: "dog", POS: "NN"}, false},
+	}
+
+	for _, c := range cases {
+		got := EndsSentence(c.ts, c.tag)
+		if got != c.want {
+			t.Errorf("EndsSentence(%s, %v) = %v, want %v", TagSetName(c.ts), c.tag, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package randtxt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTagString(t *testing.T) {
+	cases := []struct {
+		tag      Tag
+		expected string
+	}{
+		{Tag{Text: "poet", POS: "NN"}, "poet/NN"},
+		{Tag{Text: "and/or", POS: "CC"}, "and%2For/CC"},
+		{Tag{Text: "1/2", POS: "CD"}, "1%2F2/CD"},
+		{Tag{Text: "50%", POS: "NN"}, "50%25/NN"},
+		{Tag{Text: "New York", POS: "NNP"}, "New%20York/NNP"},
+		{Tag{Text: "tab\there", POS: "X"}, "tab%09here/X"},
+		{Tag{Text: "ἄνδρα", POS: "NOUN"}, "ἄνδρα/NOUN"},
+		{Tag{Text: "/", POS: "SYM"}, "%2F/SYM"},
+		{Tag{Text: "<s>", POS: "SYM"}, "%3Cs>/SYM"},
+		{Tag{Text: "<", POS: "s>"}, "%3C/s>"},
+		{Tag{Text: "a<b", POS: "SYM"}, "a<b/SYM"},
+		{Tag{Text: "#", POS: "#"}, "%23/#"},
+		{Tag{Text: "#randtxt-metadata", POS: "NN"}, "%23randtxt-metadata/NN"},
+		{SentenceStartTag, "<s>"},
+		{Tag{}, ""},
+	}
+
+	for i, c := range cases {
+		actual := c.tag.String()
+		if actual != c.expected {
+			t.Errorf("%d: got %q, want %q", i, actual, c.expected)
+		}
+
+		parsed := parseTag(actual)
+		if parsed != c.tag {
+			t.Errorf("%d: parsed %q as %#v, want %#v", i, actual, parsed, c.tag)
+		}
+	}
+}
+
+func TestParseLegacyTag(t *testing.T) {
+	cases := []struct {
+		gram     string
+		expected Tag
+	}{
+		{"and/or/CC", Tag{Text: "and/or", POS: "CC"}},
+		{"50%/NN", Tag{Text: "50%", POS: "NN"}},
+		{"%zz/NN", Tag{Text: "%zz", POS: "NN"}},
+		{"notag", Tag{}},
+	}
+
+	for i, c := range cases {
+		actual := parseTag(c.gram)
+		if actual != c.expected {
+			t.Errorf("%d: got %#v, want %#v", i, actual, c.expected)
+		}
+	}
+}
+
+func FuzzTagRoundTrip(f *testing.F) {
+	f.Add("poet", "NN")
+	f.Add("and/or", "CC")
+	f.Add("50%2F", "NN")
+	f.Add("New York", "NNP")
+	f.Add("<s>", "<s>")
+	f.Add("ἄνδρα\n", "NOUN")
+	f.Add("", "")
+	f.Add("\xff", "/")
+	f.Add("#randtxt-metadata", "NN")
+
+	f.Fuzz(func(t *testing.T, text, pos string) {
+		tag := Tag{Text: text, POS: pos}
+		raw := tag.String()
+
+		if strings.ContainsAny(raw, " \t\n\r") {
+			t.Fatalf("%#v is %q, which contains white space", tag, raw)
+		}
+
+		if !tag.IsBoundary() && !tag.IsZero() && strings.Count(raw, "/") != 1 {
+			t.Fatalf("%#v is %q, which doesn't have exactly one /", tag, raw)
+		}
+
+		parsed := parseTag(raw)
+		if parsed != tag {
+			t.Fatalf("%#v is %q, which parses as %#v", tag, raw, parsed)
+		}
+
+		if !tag.IsBoundary() && isMetadata(raw) {
+			t.Fatalf("%#v is %q, which looks like metadata", tag, raw)
+		}
+
+		// Tags must survive being joined into an ngram.
+		ngram := strings.Join([]string{raw, raw}, " ")
+		for _, gram := range strings.Split(ngram, " ") {
+			if parseTag(gram) != tag && !tag.IsZero() {
+				t.Fatalf("%#v didn't survive the ngram %q", tag, ngram)
+			}
+		}
+	})
+}
+
+func FuzzParseTag(f *testing.F) {
+	f.Add("poet/NN")
+	f.Add("and/or/CC")
+	f.Add("%2F%/%")
+	f.Add("</s>")
+
+	f.Fuzz(func(t *testing.T, gram string) {
+		tag := parseTag(gram)
+		if tag.IsZero() {
+			return
+		}
+
+		// Whatever was parsed must round trip from then on.
+		if again := parseTag(tag.String()); again != tag {
+			t.Fatalf("%q parsed as %#v, then %#v", gram, tag, again)
+		}
+	})
+}